@@ -16,6 +16,7 @@ import (
 var outputDirectory string
 var workingDirectory string
 var workspaceManifest string
+var assetPaths []string
 
 var Command = &cobra.Command{
 	Use:   "build",
@@ -44,6 +45,7 @@ var Command = &cobra.Command{
 			WorkingDirectory:  wd,
 			OutputDirectory:   od,
 			WorkspaceManifest: workspaceManifest,
+			Assets:            assetPaths,
 		})
 
 		logger.Info("collecting actions")
@@ -99,4 +101,5 @@ func init() {
 	Command.Flags().StringVarP(&outputDirectory, "output", "o", "build", "output directory")
 	Command.Flags().StringVarP(&workingDirectory, "directory", "d", "the current working directory", "directory containing the monorepo of actions")
 	Command.Flags().StringVarP(&workspaceManifest, "workspace", "w", "gamma-workspace.yml", "workspace manifest for non-javascript actions")
+	Command.Flags().StringArrayVarP(&assetPaths, "asset", "a", []string{}, "copy over an asset to each action")
 }
@@ -1,13 +1,13 @@
 package checkversions
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/spf13/cobra"
 
-	"github.com/gravitational/gamma/internal/action"
 	"github.com/gravitational/gamma/internal/git"
 	"github.com/gravitational/gamma/internal/logger"
 	"github.com/gravitational/gamma/internal/utils"
@@ -16,6 +16,10 @@ import (
 
 var workingDirectory string
 var workspaceManifest string
+var baseRevision string
+var headRevision string
+var githubURLs []string
+var backend string
 
 var Command = &cobra.Command{
 	Use:   "check-versions",
@@ -30,20 +34,11 @@ var Command = &cobra.Command{
 			logger.Fatal(err)
 		}
 
-		repo, err := git.New(wda[0])
+		repo, err := git.New(wda[0], git.Config{APIURLs: githubURLs, Backend: backend})
 		if err != nil {
 			logger.Fatal(err)
 		}
 
-		logger.Info("collecting changed files")
-
-		changed, err := repo.GetChangedFiles()
-		if err != nil {
-			logger.Fatal(err)
-		}
-
-		logger.Infof("files changed [%s]", strings.Join(changed, ", "))
-
 		ws := workspace.New(workspace.Properties{
 			WorkingDirectory:  wda[0],
 			WorkspaceManifest: workspaceManifest,
@@ -66,18 +61,13 @@ var Command = &cobra.Command{
 
 		logger.Infof("found actions [%s]", strings.Join(actionNames, ", "))
 
-		var actionsToVerify []action.Action
-
-	outer:
-		for _, action := range actions {
-			for _, file := range changed {
-				if action.Contains(file) {
-					actionsToVerify = append(actionsToVerify, action)
+		logger.Info("collecting changed actions")
 
-					continue outer
-				}
-			}
+		actionsToVerify, err := repo.ChangedActions(actions, baseRevision, headRevision)
+		if err != nil {
+			logger.Fatal(err)
 		}
+
 		if len(actionsToVerify) == 0 {
 			logger.Warning("no actions have changed, exiting")
 
@@ -95,16 +85,17 @@ var Command = &cobra.Command{
 				hasError = true
 				if err != nil {
 					logger.Errorf("error verifying action %s: %v", action.Name(), err)
+					continue
 				}
 				if exists {
-					logger.Errorf("version %s@v%s already exists", action.Name(), action.Version())
+					logger.Errorf("version %s@%s already exists as tag %s", action.Name(), action.Version(), action.Tag())
 					continue
 				}
 			}
 
 			verifyTook := time.Since(verifyStarted)
 
-			logger.Successf("successfully verified action %s@v%s in %.2fs", action.Name(), action.Version(), verifyTook.Seconds())
+			logger.Successf("successfully verified action %s@%s, tag %s is available, in %.2fs", action.Name(), action.Version(), action.Tag(), verifyTook.Seconds())
 		}
 
 		bold := text.Colors{text.FgWhite, text.Bold}
@@ -122,4 +113,8 @@ var Command = &cobra.Command{
 func init() {
 	Command.Flags().StringVarP(&workingDirectory, "directory", "d", "the current working directory", "directory containing the monorepo of actions")
 	Command.Flags().StringVarP(&workspaceManifest, "workspace", "w", "gamma-workspace.yml", "workspace manifest for non-javascript actions")
+	Command.Flags().StringVar(&baseRevision, "base", "", fmt.Sprintf("revision to detect changes from, defaults to the first parent of head, %q uses the most recent tag of each action", git.LastTag))
+	Command.Flags().StringVar(&headRevision, "head", "HEAD", "revision to detect changes up to")
+	Command.Flags().StringArrayVar(&githubURLs, "github-url", []string{}, "base URL of the API of a Github Enterprise Server, e.g. https://github.example.com/api/v3, defaults to GITHUB_API_URL, other hosts than github.com need one")
+	Command.Flags().StringVar(&backend, "backend", git.BackendGithub, fmt.Sprintf("how target repos are reached, %q uses the Github API, %q clones and pushes with git to any URL", git.BackendGithub, git.BackendGit))
 }
@@ -1,6 +1,7 @@
 package deploy
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -18,8 +19,14 @@ import (
 var outputDirectory string
 var workingDirectory string
 var workspaceManifest string
+var baseRevision string
+var headRevision string
+var githubURLs []string
+var backend string
 var pushTags *bool
+var dryRun *bool
 var assetPaths []string
+var preservePaths []string
 
 var Command = &cobra.Command{
 	Use:   "deploy",
@@ -44,24 +51,16 @@ var Command = &cobra.Command{
 			logger.Fatalf("could not create output directory: %v", err)
 		}
 
-		repo, err := git.New(wd)
+		repo, err := git.New(wd, git.Config{APIURLs: githubURLs, Backend: backend})
 		if err != nil {
 			logger.Fatal(err)
 		}
 
-		logger.Info("collecting changed files")
-
-		changed, err := repo.GetChangedFiles()
-		if err != nil {
-			logger.Fatal(err)
-		}
-
-		logger.Infof("files changed [%s]", strings.Join(changed, ", "))
-
 		ws := workspace.New(workspace.Properties{
 			WorkingDirectory:  wd,
 			OutputDirectory:   od,
 			WorkspaceManifest: workspaceManifest,
+			Assets:            assetPaths,
 		})
 
 		logger.Info("collecting actions")
@@ -82,17 +81,11 @@ var Command = &cobra.Command{
 
 		logger.Infof("found actions [%s]", strings.Join(actionNames, ", "))
 
-		var actionsToBuild []action.Action
-
-	outer:
-		for _, action := range actions {
-			for _, file := range changed {
-				if action.Contains(file) {
-					actionsToBuild = append(actionsToBuild, action)
+		logger.Info("collecting changed actions")
 
-					continue outer
-				}
-			}
+		actionsToBuild, err := repo.ChangedActions(actions, baseRevision, headRevision)
+		if err != nil {
+			logger.Fatal(err)
 		}
 
 		if len(actionsToBuild) == 0 {
@@ -119,11 +112,30 @@ var Command = &cobra.Command{
 
 			logger.Successf("successfully built action %s in %.2fs", action.Name(), buildTook.Seconds())
 
+			opts := git.DeployOptions{
+				PushTags: *pushTags,
+				Preserve: preservePaths,
+			}
+
+			if *dryRun {
+				plan, err := repo.PlanDeploy(action, opts)
+				if err != nil {
+					hasError = true
+					logger.Errorf("error planning the deploy of action %s: %v", action.Name(), err)
+
+					continue
+				}
+
+				printPlan(action, repo.Target(action), plan)
+
+				continue
+			}
+
 			logger.Infof("deploying action %s", action.Name())
 
 			deployStarted := time.Now()
 
-			if err := repo.DeployAction(action, *pushTags); err != nil {
+			if err := repo.DeployAction(action, opts); err != nil {
 				hasError = true
 				logger.Errorf("error deploying action %s: %v", action.Name(), err)
 
@@ -152,5 +164,51 @@ func init() {
 	Command.Flags().StringVarP(&workingDirectory, "directory", "d", "the current working directory", "directory containing the monorepo of actions")
 	Command.Flags().StringVarP(&workspaceManifest, "workspace", "w", "gamma-workspace.yml", "workspace manifest for non-javascript actions")
 	pushTags = Command.Flags().BoolP("push-tags", "t", false, "push the action version tags")
+	dryRun = Command.Flags().Bool("dry-run", false, "build the actions and show what would be pushed without changing the target repos")
 	Command.Flags().StringArrayVarP(&assetPaths, "asset", "a", []string{}, "copy over an asset to each action")
+	Command.Flags().StringArrayVarP(&preservePaths, "preserve", "p", []string{}, "keep a path of the target repo that isn't part of the build output, e.g. .github/ or LICENSE")
+	Command.Flags().StringVar(&baseRevision, "base", "", fmt.Sprintf("revision to detect changes from, defaults to the first parent of head, %q uses the most recent tag of each action", git.LastTag))
+	Command.Flags().StringVar(&headRevision, "head", "HEAD", "revision to detect changes up to")
+	Command.Flags().StringArrayVar(&githubURLs, "github-url", []string{}, "base URL of the API of a Github Enterprise Server, e.g. https://github.example.com/api/v3, defaults to GITHUB_API_URL, other hosts than github.com need one")
+	Command.Flags().StringVar(&backend, "backend", git.BackendGithub, fmt.Sprintf("how target repos are reached, %q uses the Github API, %q clones and pushes with git to any URL", git.BackendGithub, git.BackendGit))
+}
+
+// printPlan shows the commit and tag a deploy would push
+func printPlan(a action.Action, target string, plan *git.DeployPlan) {
+	parent := plan.Parent
+	if parent == "" {
+		parent = "an empty repo"
+	}
+
+	logger.Infof("would push action %s to %s of %s on top of %s", a.Name(), plan.Ref, target, parent)
+
+	if plan.NewBranch && plan.Parent != "" {
+		fmt.Println("  new branch, created from the default branch")
+	}
+
+	fmt.Println("  message:")
+	for _, line := range strings.Split(strings.TrimRight(plan.Message, "\n"), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+
+	if plan.Tag != "" {
+		fmt.Printf("  tag: %s\n", plan.Tag)
+	}
+
+	fmt.Println("  tree:")
+
+	var unchanged int
+	for _, entry := range plan.Entries {
+		if entry.Status == git.EntryUnchanged {
+			unchanged++
+
+			continue
+		}
+
+		fmt.Printf("    %-9s %s %s\n", entry.Status, entry.Mode, entry.Path)
+	}
+
+	if unchanged > 0 {
+		fmt.Printf("    %d unchanged files\n", unchanged)
+	}
 }
@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/spf13/cobra"
+
+	"github.com/gravitational/gamma/internal/git"
+	"github.com/gravitational/gamma/internal/logger"
+	"github.com/gravitational/gamma/internal/utils"
+	"github.com/gravitational/gamma/internal/workspace"
+)
+
+var outputDirectory string
+var workingDirectory string
+var workspaceManifest string
+var baseRevision string
+var headRevision string
+var githubURLs []string
+var backend string
+var assetPaths []string
+var preservePaths []string
+var stat bool
+var nameOnly bool
+
+var Command = &cobra.Command{
+	Use:   "diff",
+	Short: "Shows what a deploy would change",
+	Long:  `Builds the actions that have changes and compares them with the branch of their target repo. Exits with 1 when a deploy would change anything.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		started := time.Now()
+
+		if stat && nameOnly {
+			logger.Fatal("--stat and --name-only cannot be used together")
+		}
+
+		workingDirectory = utils.FetchWorkingDirectory(workingDirectory)
+
+		nd, err := utils.NormalizeDirectories(workingDirectory, outputDirectory)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		wd, od := nd[0], nd[1]
+
+		if err := os.RemoveAll(od); err != nil {
+			logger.Fatalf("could not remove output directory: %v", err)
+		}
+
+		if err := os.Mkdir(od, 0755); err != nil {
+			logger.Fatalf("could not create output directory: %v", err)
+		}
+
+		repo, err := git.New(wd, git.Config{APIURLs: githubURLs, Backend: backend})
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		ws := workspace.New(workspace.Properties{
+			WorkingDirectory:  wd,
+			OutputDirectory:   od,
+			WorkspaceManifest: workspaceManifest,
+			Assets:            assetPaths,
+		})
+
+		logger.Info("collecting actions")
+
+		actions, err := ws.CollectActions(true)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if len(actions) == 0 {
+			logger.Fatal("could not find any actions")
+		}
+
+		logger.Info("collecting changed actions")
+
+		actionsToDiff, err := repo.ChangedActions(actions, baseRevision, headRevision)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if len(actionsToDiff) == 0 {
+			logger.Warning("no actions have changed, exiting")
+
+			return
+		}
+
+		var hasError bool
+		var hasChanges bool
+
+		for _, action := range actionsToDiff {
+			logger.Infof("action %s has changes, building", action.Name())
+
+			if err := action.Build(); err != nil {
+				hasError = true
+				logger.Errorf("error building action %s: %v", action.Name(), err)
+
+				continue
+			}
+
+			changes, err := repo.DiffAction(action, preservePaths)
+			if err != nil {
+				hasError = true
+				logger.Errorf("error comparing action %s: %v", action.Name(), err)
+
+				continue
+			}
+
+			if len(changes) == 0 {
+				logger.Successf("action %s is up to date in %s", action.Name(), repo.Target(action))
+
+				continue
+			}
+
+			hasChanges = true
+
+			logger.Infof("action %s differs from %s", action.Name(), repo.Target(action))
+
+			// the paths start with the name of the target repo, like its clone
+			prefix := strings.TrimSuffix(path.Base(repo.Target(action)), ".git") + "/"
+
+			switch {
+			case stat:
+				err = git.WriteStat(os.Stdout, changes, prefix)
+			case nameOnly:
+				err = git.WriteNameOnly(os.Stdout, changes, prefix)
+			default:
+				err = git.WritePatch(os.Stdout, changes, prefix)
+			}
+
+			if err != nil {
+				logger.Fatalf("could not write the diff of action %s: %v", action.Name(), err)
+			}
+		}
+
+		bold := text.Colors{text.FgWhite, text.Bold}
+
+		took := time.Since(started)
+
+		if hasError {
+			logger.Fatal(bold.Sprintf("completed with errors in %.2fs", took.Seconds()))
+		}
+
+		if hasChanges {
+			logger.Warning(bold.Sprintf("found differences in %.2fs", took.Seconds()))
+			os.Exit(1)
+		}
+
+		logger.Success(bold.Sprintf("done in %.2fs", took.Seconds()))
+	},
+}
+
+func init() {
+	Command.Flags().StringVarP(&outputDirectory, "output", "o", "build", "output directory")
+	Command.Flags().StringVarP(&workingDirectory, "directory", "d", "the current working directory", "directory containing the monorepo of actions")
+	Command.Flags().StringVarP(&workspaceManifest, "workspace", "w", "gamma-workspace.yml", "workspace manifest for non-javascript actions")
+	Command.Flags().StringArrayVarP(&assetPaths, "asset", "a", []string{}, "copy over an asset to each action")
+	Command.Flags().StringArrayVarP(&preservePaths, "preserve", "p", []string{}, "keep a path of the target repo that isn't part of the build output, e.g. .github/ or LICENSE")
+	Command.Flags().StringVar(&baseRevision, "base", "", fmt.Sprintf("revision to detect changes from, defaults to the first parent of head, %q uses the most recent tag of each action", git.LastTag))
+	Command.Flags().StringVar(&headRevision, "head", "HEAD", "revision to detect changes up to")
+	Command.Flags().StringArrayVar(&githubURLs, "github-url", []string{}, "base URL of the API of a Github Enterprise Server, e.g. https://github.example.com/api/v3, defaults to GITHUB_API_URL, other hosts than github.com need one")
+	Command.Flags().StringVar(&backend, "backend", git.BackendGithub, fmt.Sprintf("how target repos are reached, %q uses the Github API, %q clones and pushes with git to any URL", git.BackendGithub, git.BackendGit))
+	Command.Flags().BoolVar(&stat, "stat", false, "only show the number of changed lines per file")
+	Command.Flags().BoolVar(&nameOnly, "name-only", false, "only show the names of the changed files")
+}
@@ -42,7 +42,7 @@ var Command = &cobra.Command{
 		targetAction := actionsMap[args[0]]
 		s, err := targetAction.GetActionYAML()
 		if err != nil {
-			logger.Errorf("error merging action %s: %v", targetAction.Name(), err)
+			logger.Fatalf("error merging action %s: %v", targetAction.Name(), err)
 		}
 		fmt.Println(*s)
 	},
@@ -6,8 +6,10 @@ import (
 	"github.com/gravitational/gamma/cmd/build"
 	"github.com/gravitational/gamma/cmd/checkversions"
 	"github.com/gravitational/gamma/cmd/deploy"
+	"github.com/gravitational/gamma/cmd/diff"
 	"github.com/gravitational/gamma/cmd/list"
 	"github.com/gravitational/gamma/cmd/merge"
+	"github.com/gravitational/gamma/cmd/validate"
 	"github.com/gravitational/gamma/internal/color"
 )
 
@@ -39,6 +41,8 @@ func init() {
 	rootCmd.AddCommand(checkversions.Command)
 	rootCmd.AddCommand(merge.Command)
 	rootCmd.AddCommand(deploy.Command)
+	rootCmd.AddCommand(diff.Command)
+	rootCmd.AddCommand(validate.Command)
 
 	rootCmd.SetHelpTemplate(`{{ logo }}
 
@@ -88,8 +92,12 @@ func colorize(s, name string) string {
 		return color.Purple(name)
 	case deploy.Command.Name():
 		return color.Teal(name)
+	case diff.Command.Name():
+		return color.Yellow(name)
 	case merge.Command.Name():
 		return color.Magenta(name)
+	case validate.Command.Name():
+		return color.Green(name)
 	case "help":
 		return color.Purple(name)
 	case "completion":
@@ -109,8 +117,12 @@ func emoji(s string) string {
 		return "🔍"
 	case deploy.Command.Name():
 		return "🚀"
+	case diff.Command.Name():
+		return "📝"
 	case merge.Command.Name():
 		return "🧪"
+	case validate.Command.Name():
+		return "✅"
 	case "help":
 		return "❓"
 	case "completion":
@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/spf13/cobra"
+
+	"github.com/gravitational/gamma/internal/logger"
+	"github.com/gravitational/gamma/internal/utils"
+	"github.com/gravitational/gamma/internal/workspace"
+)
+
+var workingDirectory string
+var workspaceManifest string
+
+var Command = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate all the actions in the monorepo",
+	Long:  `Validates the merged action.yml of every action in the monorepo against the Github Actions metadata syntax and reports all the problems found.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		started := time.Now()
+
+		workingDirectory = utils.FetchWorkingDirectory(workingDirectory)
+
+		nd, err := utils.NormalizeDirectories(workingDirectory)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		ws := workspace.New(workspace.Properties{
+			WorkingDirectory:  nd[0],
+			WorkspaceManifest: workspaceManifest,
+		})
+
+		logger.Info("collecting actions")
+
+		actions, err := ws.CollectActions(true)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if len(actions) == 0 {
+			logger.Fatal("could not find any actions")
+		}
+
+		var hasError bool
+
+		for _, action := range actions {
+			problems, err := action.Validate()
+			if err != nil {
+				hasError = true
+				logger.Errorf("error validating action %s: %v", action.Name(), err)
+
+				continue
+			}
+
+			if len(problems) == 0 {
+				logger.Successf("action %s is valid", action.Name())
+
+				continue
+			}
+
+			hasError = true
+			logger.Errorf("action %s has %d problem(s):", action.Name(), len(problems))
+
+			for _, problem := range problems {
+				if rel, err := filepath.Rel(nd[0], problem.Position.File); err == nil {
+					problem.Position.File = rel
+				}
+
+				logger.Errorf("  %s", problem)
+			}
+		}
+
+		bold := text.Colors{text.FgWhite, text.Bold}
+
+		took := time.Since(started)
+
+		if hasError {
+			logger.Fatal(bold.Sprintf("completed with errors in %.2fs", took.Seconds()))
+		}
+
+		logger.Success(bold.Sprintf("done in %.2fs", took.Seconds()))
+	},
+}
+
+func init() {
+	Command.Flags().StringVarP(&workingDirectory, "directory", "d", "the current working directory", "directory containing the monorepo of actions")
+	Command.Flags().StringVarP(&workspaceManifest, "workspace", "w", "gamma-workspace.yml", "workspace manifest for non-javascript actions")
+}
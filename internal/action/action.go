@@ -2,20 +2,22 @@ package action
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 
 	"github.com/gravitational/gamma/internal/node"
+	"github.com/gravitational/gamma/internal/runner"
 	"github.com/gravitational/gamma/internal/schema"
 	"github.com/gravitational/gamma/internal/utils"
 	publicshema "github.com/gravitational/gamma/pkg/schema"
@@ -37,8 +39,17 @@ type action struct {
 	actionInfo       *publicshema.ActionInfo
 	outputDirectory  string
 	workingDirectory string
+	repositoryURL    string
+	host             string
 	owner            string
 	repoName         string
+	branch           string
+	tag              string
+	tagPattern       string
+	runner           *runner.Runner
+	assets           []string
+	files            []string
+	parser           *schema.Parser
 }
 
 type Config struct {
@@ -47,16 +58,31 @@ type Config struct {
 	OutputDirectory  string
 	PackageInfo      *node.PackageInfo
 	ActionInfo       *publicshema.ActionInfo
+	// Build is the build config of the workspace, which the action can override
+	Build *publicshema.BuildConfig
+	// Release is where the actions of the workspace are published, which the
+	// action can override
+	Release publicshema.Release
+	// Assets are copied into the output of the action, relative to the working directory
+	Assets []string
+	// Parser resolves the action definition, shared by the actions of a workspace
+	Parser *schema.Parser
 }
 
 type Action interface {
 	Build() error
+	Validate() ([]schema.Problem, error)
 	GetActionYAML() (*string, error)
 	Name() string
 	Version() string
 	Path() string
+	RepositoryURL() string
+	Host() string
 	Owner() string
 	RepoName() string
+	Branch() string
+	Tag() string
+	TagPattern() string
 	OutputDirectory() string
 	Contains(filename string) bool
 }
@@ -64,13 +90,32 @@ type Action interface {
 func New(config *Config) (Action, error) {
 	var uriString string
 	var kind Kind
+	var buildConfig *publicshema.BuildConfig
+	var assets []string
+	var files []string
+	var release publicshema.Release
 
 	actionInfo := config.ActionInfo
 
+	parser := config.Parser
+	if parser == nil {
+		parser = schema.NewParser(config.WorkingDirectory)
+	}
+
 	switch {
 	case config.PackageInfo != nil && config.PackageInfo.Repository != nil:
 		kind = Javascript
 		uriString = config.PackageInfo.Repository.URL
+		// javascript actions are always built, with the default runner if need be
+		buildConfig = &publicshema.BuildConfig{}
+		if config.PackageInfo.Gamma != nil {
+			if config.PackageInfo.Gamma.Build != nil {
+				buildConfig = config.PackageInfo.Gamma.Build
+			}
+			assets = config.PackageInfo.Gamma.Assets
+			files = config.PackageInfo.Gamma.Files
+			release = config.PackageInfo.Gamma.Release
+		}
 	case actionInfo != nil:
 		kind = Composite
 		uriString = config.ActionInfo.RepositoryURL
@@ -87,27 +132,84 @@ func New(config *Config) (Action, error) {
 
 		actionInfo = structCopy.(*publicshema.ActionInfo)
 		actionInfo.OutputDirectory = oda[0]
+
+		buildConfig = actionInfo.Build
+		assets = actionInfo.Assets
+		files = actionInfo.Files
+		release = actionInfo.Release
 	default:
 		return nil, errors.New("repository field missing in Action")
 	}
 
-	uri, err := url.Parse(uriString)
+	host, owner, repoName, err := parseRepositoryURL(uriString)
 	if err != nil {
 		return nil, err
 	}
 
-	parts := strings.Split(uri.Path[1:], "/")
-
-	return &action{
+	a := &action{
 		kind:             kind,
 		name:             config.Name,
 		packageInfo:      config.PackageInfo,
 		actionInfo:       actionInfo,
 		outputDirectory:  config.OutputDirectory,
 		workingDirectory: config.WorkingDirectory,
-		owner:            parts[0],
-		repoName:         strings.TrimSuffix(parts[1], ".git"),
-	}, nil
+		repositoryURL:    uriString,
+		host:             host,
+		owner:            owner,
+		repoName:         repoName,
+		assets:           mergeAssets(config.Assets, assets),
+		files:            append(defaultFiles(), files...),
+		parser:           parser,
+	}
+
+	if err := a.setRelease(mergeRelease(config.Release, release)); err != nil {
+		return nil, fmt.Errorf("invalid release config for %s: %v", a.Name(), err)
+	}
+
+	if buildConfig != nil {
+		r, err := runner.New(runner.Merge(config.Build, buildConfig), a.Name(), a.Path(), a.workingDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid build config for %s: %v", a.Name(), err)
+		}
+
+		a.runner = r
+	}
+
+	return a, nil
+}
+
+var scpURL = regexp.MustCompile(`^[\w.-]+@([\w.-]+):(.+)$`)
+
+// parseRepositoryURL returns the host, owner and name of a repository URL,
+// including scp-like ssh URLs such as git@github.com:owner/repo.git. Owner
+// and name are empty when the path isn't owner/repo, e.g. file:///repo.git,
+// which only the git backend deploys to.
+func parseRepositoryURL(uriString string) (string, string, string, error) {
+	if m := scpURL.FindStringSubmatch(uriString); m != nil {
+		uriString = fmt.Sprintf("ssh://%s/%s", m[1], m[2])
+	}
+
+	uri, err := url.Parse(uriString)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var owner, repo string
+	if parts := strings.Split(strings.Trim(uri.Path, "/"), "/"); len(parts) >= 2 {
+		owner, repo = parts[0], strings.TrimSuffix(parts[1], ".git")
+	}
+
+	host := uri.Host
+	if !strings.HasPrefix(uri.Scheme, "http") && !strings.HasSuffix(uri.Scheme, "+https") {
+		// the port of a git or ssh URL isn't the port of the API
+		host = uri.Hostname()
+	}
+
+	if host == "" {
+		host = "github.com"
+	}
+
+	return host, owner, repo, nil
 }
 
 func (a *action) Name() string {
@@ -145,6 +247,16 @@ func (a *action) OutputDirectory() string {
 	return a.outputDirectory
 }
 
+// RepositoryURL returns the URL of the target repo, as configured
+func (a *action) RepositoryURL() string {
+	return a.repositoryURL
+}
+
+// Host returns the host of the target repo, e.g. github.com
+func (a *action) Host() string {
+	return a.host
+}
+
 func (a *action) Owner() string {
 	return a.owner
 }
@@ -156,13 +268,11 @@ func (a *action) Contains(filename string) bool {
 }
 
 func (a *action) buildPackage() error {
-	if a.kind != Javascript {
-		return fmt.Errorf("action %s is not a Javascript action, can't build package", a.name)
+	if a.runner == nil {
+		return fmt.Errorf("action %s has no build config, can't build package", a.Name())
 	}
-	cmd := exec.Command("pnpm", "exec", "nx", "run", fmt.Sprintf("%s:build", a.packageInfo.Name))
-	cmd.Dir = a.packageInfo.Path
 
-	if err := a.runCommand(cmd); err != nil {
+	if err := a.runCommand(a.runner.Command()); err != nil {
 		return err
 	}
 
@@ -199,8 +309,12 @@ func (a *action) runCommand(cmd *exec.Cmd) error {
 }
 
 func (a *action) movePackage() error {
-	dist := path.Join(a.packageInfo.Path, "dist")
-	destination := path.Join(a.outputDirectory, "dist")
+	dist := path.Join(a.Path(), a.runner.Dist())
+	destination := path.Join(a.outputDirectory, a.runner.Dist())
+
+	if err := os.MkdirAll(path.Dir(destination), 0755); err != nil {
+		return err
+	}
 
 	if err := os.Rename(dist, destination); err != nil {
 		return err
@@ -214,72 +328,187 @@ func (a *action) movePackage() error {
 func (a *action) createActionYAML(write bool) (*string, error) {
 	filename := path.Join(a.Path(), "action.yml")
 
-	definition, err := schema.GetConfig(a.workingDirectory, filename)
+	definition, err := a.parser.GetConfig(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	bytes, err := yaml.Marshal(definition)
-	if err != nil {
+	var node yaml.Node
+	if err := node.Encode(definition); err != nil {
+		return nil, err
+	}
+
+	a.parser.RestoreLayout(definition, &node)
+
+	// templates are opt-in, existing scripts may contain literal braces
+	if definition.Vars != nil {
+		data := a.templateData()
+		data.Vars = definition.Vars
+
+		if err := schema.Interpolate(&node, data); err != nil {
+			return nil, fmt.Errorf("could not interpolate %s: %v", filename, err)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
 		return nil, err
 	}
 
 	if write {
 		output := path.Join(a.outputDirectory, "action.yml")
-		if err := os.WriteFile(output, bytes, 0644); err != nil {
+		if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
 			return nil, fmt.Errorf("could not create action.yml: %v", err)
 		}
 	}
 
-	str := string(bytes)
+	str := buf.String()
 	return &str, nil
 }
 
-func (a *action) copyFile(file string) error {
-	src := path.Join(a.Path(), file)
-	dst := path.Join(a.outputDirectory, file)
-
-	if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
-		return nil
-	}
-
-	source, err := os.Open(src)
-	if err != nil {
-		return err
+// defaultFiles are always part of the output, unless excluded
+func defaultFiles() []string {
+	return []string{
+		"README.md",
 	}
+}
 
-	defer source.Close()
+// isIncluded evaluates the file patterns in order against the file and its
+// parent directories, the last matching pattern wins. Patterns starting with
+// ! exclude files.
+func isIncluded(patterns []string, file string) bool {
+	var included bool
 
-	destination, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("could not create file: %v", err)
-	}
+	for _, pattern := range patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "!"), "/")
 
-	defer destination.Close()
+		for p := file; p != "."; p = path.Dir(p) {
+			if utils.MatchGlob(pattern, p) {
+				included = !exclude
 
-	if _, err := io.Copy(destination, source); err != nil {
-		return err
+				break
+			}
+		}
 	}
 
-	return nil
+	return included
 }
 
+// copyFiles copies the included files of the action into the output
+// directory, keeping their layout
 func (a *action) copyFiles() error {
-	files := []string{
-		"README.md",
+	patterns := a.files
+
+	if a.kind == Docker {
+		context, err := a.dockerFiles()
+		if err != nil {
+			return err
+		}
+
+		// the build context comes first so that files of the action can
+		// still exclude parts of it
+		patterns = append(context, a.files...)
 	}
 
-	var eg errgroup.Group
+	return filepath.Walk(a.Path(), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(a.Path(), p)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			switch {
+			case info.Name() == "node_modules" || info.Name() == ".git":
+				return filepath.SkipDir
+			case a.runner != nil && rel == a.runner.Dist():
+				// moved over by the build
+				return filepath.SkipDir
+			}
 
-	for _, file := range files {
-		f := file
-		eg.Go(func() error {
-			return a.copyFile(f)
-		})
+			return nil
+		}
+
+		// action.yml is generated
+		if rel == "action.yml" || rel == "action.yaml" {
+			return nil
+		}
+
+		if !isIncluded(patterns, rel) {
+			return nil
+		}
+
+		if err := utils.CopyPath(p, path.Join(a.outputDirectory, rel)); err != nil {
+			return fmt.Errorf("could not copy %s: %v", rel, err)
+		}
+
+		return nil
+	})
+}
+
+// mergeAssets adds the assets of an action to the ones of the workspace. An
+// action asset starting with ! removes the matching workspace assets instead.
+func mergeAssets(workspace, action []string) []string {
+	merged := append([]string{}, workspace...)
+
+	for _, asset := range action {
+		if !strings.HasPrefix(asset, "!") {
+			merged = append(merged, asset)
+
+			continue
+		}
+
+		pattern := strings.TrimPrefix(asset, "!")
+
+		var kept []string
+		for _, m := range merged {
+			if ok, _ := path.Match(pattern, m); ok || m == pattern {
+				continue
+			}
+
+			kept = append(kept, m)
+		}
+
+		merged = kept
 	}
 
-	if err := eg.Wait(); err != nil {
-		return err
+	return merged
+}
+
+// copyAssets copies the files, directories and globs of the assets into the
+// root of the output directory
+func (a *action) copyAssets() error {
+	for _, asset := range a.assets {
+		pattern := path.Join(a.workingDirectory, strings.TrimPrefix(asset, "@/"))
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid asset %s: %v", asset, err)
+		}
+
+		if len(matches) == 0 {
+			return fmt.Errorf("asset %s does not match any file", asset)
+		}
+
+		for _, match := range matches {
+			if err := utils.CopyPath(match, path.Join(a.outputDirectory, path.Base(match))); err != nil {
+				return fmt.Errorf("could not copy asset %s: %v", match, err)
+			}
+		}
 	}
 
 	return nil
@@ -293,19 +522,47 @@ func (a *action) createOutputDirectory() error {
 	return nil
 }
 
+// detectDocker turns a composite action into a Docker action when its
+// action.yml runs a Docker image. It is only parsed when building, so that
+// commands which don't build don't depend on it.
+func (a *action) detectDocker() error {
+	if a.kind != Composite {
+		return nil
+	}
+
+	definition, err := a.parser.GetConfig(path.Join(a.Path(), "action.yml"))
+	if err != nil {
+		return err
+	}
+
+	if definition.Runs.DockerRun != nil {
+		a.kind = Docker
+	}
+
+	return nil
+}
+
 func (a *action) Build() error {
+	if err := a.detectDocker(); err != nil {
+		return err
+	}
+
 	if err := a.createOutputDirectory(); err != nil {
 		return fmt.Errorf("could not create output directory: %v", err)
 	}
 
+	// files of the action itself take precedence over the shared assets
+	if err := a.copyAssets(); err != nil {
+		return err
+	}
+
 	var eg errgroup.Group
 
 	eg.Go(func() error {
 		_, err := a.createActionYAML(true)
 		return err
 	})
-	eg.Go(a.copyFiles)
-	if a.kind == Javascript {
+	if a.runner != nil {
 		eg.Go(a.buildPackage)
 	}
 
@@ -313,9 +570,70 @@ func (a *action) Build() error {
 		return err
 	}
 
+	// the build writes into the action and moves its dist out, so the files
+	// are only walked once it is done
+	if err := a.copyFiles(); err != nil {
+		return err
+	}
+
+	if a.kind == Docker {
+		definition, err := a.parser.GetConfig(path.Join(a.Path(), "action.yml"))
+		if err != nil {
+			return err
+		}
+
+		if problems := validateDocker(definition, a.outputDirectory); len(problems) > 0 {
+			var messages []string
+			for _, problem := range problems {
+				messages = append(messages, problem.Message)
+			}
+
+			return fmt.Errorf("invalid docker action: %s", strings.Join(messages, ", "))
+		}
+	}
+
 	return nil
 }
 
+// Validate checks the merged action.yml and the files it refers to, which
+// can be either in the action or in its build output
+func (a *action) Validate() ([]schema.Problem, error) {
+	definition, err := a.parser.GetConfig(path.Join(a.Path(), "action.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	problems, err := schema.Validate(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	if run := definition.Runs.JavascriptRun; run != nil {
+		scripts := map[string]*string{
+			"main": &run.Main,
+			"pre":  run.Pre,
+			"post": run.Post,
+		}
+
+		for _, key := range []string{"main", "pre", "post"} {
+			script := scripts[key]
+			if script == nil {
+				continue
+			}
+
+			if !exists(a.Path(), *script) && !exists(a.outputDirectory, *script) {
+				problems = append(problems, schema.NewProblem(definition, fmt.Sprintf("runs.%s %s does not exist, has the action been built?", key, *script), "runs", key))
+			}
+		}
+	}
+
+	if definition.Runs.DockerRun != nil {
+		problems = append(problems, validateDocker(definition, a.Path())...)
+	}
+
+	return problems, nil
+}
+
 func (a *action) GetActionYAML() (*string, error) {
 	return a.createActionYAML(false)
 }
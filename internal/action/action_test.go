@@ -0,0 +1,94 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	publicshema "github.com/gravitational/gamma/pkg/schema"
+)
+
+const sharedVars = `vars:
+  team: platform
+inputs:
+  token:
+    description: A token
+`
+
+func TestTemplatesAreOptIn(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		want       []string
+	}{
+		{
+			name: "extended vars",
+			definition: `name: Example
+description: Maintained by {{ .Vars.team }}
+extend:
+  - from: shared.yml
+runs:
+  using: composite
+  steps:
+    - run: docker inspect --format '{{.State.Running}}' app
+      shell: bash
+`,
+			want: []string{"Maintained by {{ .Vars.team }}", "docker inspect --format '{{.State.Running}}' app"},
+		},
+		{
+			name: "own vars",
+			definition: `name: Example
+description: Maintained by {{ .Vars.team }}
+vars: {}
+extend:
+  - from: shared.yml
+runs:
+  using: composite
+  steps:
+    - run: docker inspect --format '{{ "{{" }}.State.Running}}' app
+      shell: bash
+`,
+			want: []string{"Maintained by platform", "docker inspect --format '{{.State.Running}}' app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if err := os.WriteFile(filepath.Join(dir, "shared.yml"), []byte(sharedVars), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, "action.yml"), []byte(tt.definition), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := New(&Config{
+				Name:             "example",
+				WorkingDirectory: dir,
+				ActionInfo: &publicshema.ActionInfo{
+					Name:            "example",
+					Version:         "1.0.0",
+					OutputDirectory: dir,
+					RepositoryURL:   "https://github.com/owner/example",
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := a.GetActionYAML()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(*out, want) {
+					t.Errorf("expected %q in:\n%s", want, *out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package action
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/gamma/internal/schema"
+	publicshema "github.com/gravitational/gamma/pkg/schema"
+)
+
+// dockerReference matches docker://[host[:port]/]name[:tag][@digest]
+var dockerReference = regexp.MustCompile(`^docker://` +
+	`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?` +
+	`(?:@sha256:[a-f0-9]{64})?$`)
+
+// dockerFiles returns file patterns publishing the whole build context of
+// the action, minus what .dockerignore excludes
+func (a *action) dockerFiles() ([]string, error) {
+	patterns := []string{"**"}
+
+	file, err := os.Open(path.Join(a.Path(), ".dockerignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return patterns, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read .dockerignore: %v", err)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the meaning of ! is reversed, it re-includes files
+		if strings.HasPrefix(line, "!") {
+			patterns = append(patterns, strings.TrimPrefix(strings.TrimPrefix(line, "!"), "/"))
+		} else {
+			patterns = append(patterns, "!"+strings.TrimPrefix(line, "/"))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read .dockerignore: %v", err)
+	}
+
+	return patterns, nil
+}
+
+// validateDocker checks the image, entrypoints and arguments of a Docker
+// action against the files in dir
+func validateDocker(definition *publicshema.Config, dir string) []schema.Problem {
+	var problems []schema.Problem
+
+	run := definition.Runs.DockerRun
+
+	switch {
+	case strings.HasPrefix(run.Image, "docker://"):
+		if !dockerReference.MatchString(run.Image) {
+			problems = append(problems, schema.NewProblem(definition, fmt.Sprintf("runs.image %s is not a valid docker reference", run.Image), "runs", "image"))
+		}
+	case run.Image == "":
+		problems = append(problems, schema.NewProblem(definition, "runs.image is empty", "runs", "image"))
+	default:
+		info, err := os.Stat(path.Join(dir, run.Image))
+		if err != nil || !info.Mode().IsRegular() {
+			problems = append(problems, schema.NewProblem(definition, fmt.Sprintf("runs.image %s is not a Dockerfile of the action", run.Image), "runs", "image"))
+		}
+	}
+
+	entrypoints := map[string]*string{
+		"pre-entrypoint":  run.PreEntrypoint,
+		"entrypoint":      run.Entrypoint,
+		"post-entrypoint": run.PostEntrypoint,
+	}
+
+	for _, key := range []string{"pre-entrypoint", "entrypoint", "post-entrypoint"} {
+		entrypoint := entrypoints[key]
+		if entrypoint == nil || !isLocalPath(*entrypoint) {
+			continue
+		}
+
+		if !exists(dir, *entrypoint) {
+			problems = append(problems, schema.NewProblem(definition, fmt.Sprintf("runs.%s %s does not exist in the action", key, *entrypoint), "runs", key))
+		}
+	}
+
+	if run.Args != nil {
+		for i, arg := range *run.Args {
+			// only explicitly relative arguments are meant to be files
+			if !strings.HasPrefix(arg, "./") {
+				continue
+			}
+
+			if !exists(dir, arg) {
+				problems = append(problems, schema.NewProblem(definition, fmt.Sprintf("runs.args[%d] %s does not exist in the action", i, arg), "runs", "args", strconv.Itoa(i)))
+			}
+		}
+	}
+
+	return problems
+}
+
+func exists(dir, file string) bool {
+	_, err := os.Stat(path.Join(dir, file))
+
+	return err == nil
+}
+
+// isLocalPath reports whether the value looks like a file of the action
+// rather than a command, an absolute path in the image or an expression
+func isLocalPath(value string) bool {
+	if value == "" || path.IsAbs(value) || strings.Contains(value, "${{") {
+		return false
+	}
+
+	return strings.Contains(value, "/") || path.Ext(value) != ""
+}
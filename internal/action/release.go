@@ -0,0 +1,120 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/gravitational/gamma/internal/schema"
+	publicshema "github.com/gravitational/gamma/pkg/schema"
+)
+
+// defaultTag is the tag template used when neither the workspace nor the
+// action sets one
+const defaultTag = "v{{ .Action.Version }}"
+
+// mergeRelease overrides the release config of the workspace with the fields
+// set by the action
+func mergeRelease(workspace, action publicshema.Release) publicshema.Release {
+	if action.Branch != "" {
+		workspace.Branch = action.Branch
+	}
+
+	if action.Tag != "" {
+		workspace.Tag = action.Tag
+	}
+
+	return workspace
+}
+
+// setRelease renders the branch and tag templates of the action, which have
+// to be valid git ref names
+func (a *action) setRelease(release publicshema.Release) error {
+	data := a.templateData()
+
+	branch, err := schema.Render(release.Branch, data)
+	if err != nil {
+		return err
+	}
+
+	if branch != "" && plumbing.NewBranchReferenceName(branch).Validate() != nil {
+		return fmt.Errorf("the branch template evaluates to %q, which is not a valid branch name", branch)
+	}
+
+	if release.Tag == "" {
+		release.Tag = defaultTag
+	}
+
+	tag, err := schema.Render(release.Tag, data)
+	if err != nil {
+		return err
+	}
+
+	if tag == "" {
+		return errors.New("the tag template evaluates to an empty tag")
+	}
+
+	if plumbing.NewTagReferenceName(tag).Validate() != nil {
+		return fmt.Errorf("the tag template evaluates to %q, which is not a valid tag name", tag)
+	}
+
+	// the tags of every version of the action
+	data.Action.Version, data.Action.Major, data.Action.Minor, data.Action.Patch = "*", "*", "*", "*"
+
+	tagPattern, err := schema.Render(release.Tag, data)
+	if err != nil {
+		return err
+	}
+
+	a.branch, a.tag, a.tagPattern = branch, tag, tagPattern
+
+	return nil
+}
+
+// Branch returns the branch of the target repo the action is deployed to,
+// empty for the branch checked out in the monorepo
+func (a *action) Branch() string {
+	return a.branch
+}
+
+// Tag returns the tag of the current version of the action
+func (a *action) Tag() string {
+	return a.tag
+}
+
+// TagPattern matches the tags of every version of the action, e.g. v*
+func (a *action) TagPattern() string {
+	return a.tagPattern
+}
+
+func (a *action) templateData() schema.TemplateData {
+	major, minor, patch := versionParts(a.Version())
+
+	return schema.TemplateData{
+		Action: schema.ActionData{
+			Name:    a.Name(),
+			Version: a.Version(),
+			Major:   major,
+			Minor:   minor,
+			Patch:   patch,
+		},
+		Repo: schema.RepoData{Owner: a.Owner(), Name: a.RepoName()},
+	}
+}
+
+// versionParts splits a semantic version, the patch excludes pre-release and
+// build metadata, e.g. 1.2.3-rc.1 is 1, 2 and 3
+func versionParts(version string) (string, string, string) {
+	if i := strings.IndexAny(version, "-+"); i != -1 {
+		version = version[:i]
+	}
+
+	parts := strings.SplitN(version, ".", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+
+	return parts[0], parts[1], parts[2]
+}
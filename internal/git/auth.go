@@ -0,0 +1,343 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/google/go-github/v48/github"
+)
+
+const defaultAPIURL = "https://api.github.com/"
+
+// authenticator creates the Github client used for the repos of an owner on
+// a host
+type authenticator interface {
+	client(ctx context.Context, host, owner string) (*github.Client, error)
+}
+
+// newAuthenticator picks the first configured credentials: a Github app,
+// then a token from GITHUB_TOKEN or GH_TOKEN
+func newAuthenticator(apiURLs []string) (authenticator, error) {
+	endpoints, err := newEndpoints(apiURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Getenv("GITHUB_APP_ID") != "" {
+		return newAppAuthenticator(endpoints)
+	}
+
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return &tokenAuthenticator{
+				token:     token,
+				endpoints: endpoints,
+				clients:   make(map[string]*github.Client),
+			}, nil
+		}
+	}
+
+	return nil, errors.New("set GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY or GITHUB_APP_PRIVATE_KEY_PATH to authenticate as a Github app, or a token as GITHUB_TOKEN or GH_TOKEN")
+}
+
+// endpoints maps the hosts of repositories to the base URL of their API
+type endpoints map[string]string
+
+// newEndpoints reads the API URLs of GITHUB_API_URL and apiURLs, the latter
+// taking precedence for the same host
+func newEndpoints(apiURLs []string) (endpoints, error) {
+	urls := apiURLs
+	if env := os.Getenv("GITHUB_API_URL"); env != "" {
+		urls = append([]string{env}, apiURLs...)
+	}
+
+	e := make(endpoints)
+
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid Github API URL %s, expected e.g. https://github.example.com/api/v3", u)
+		}
+
+		if !strings.HasSuffix(u, "/") {
+			u += "/"
+		}
+
+		// api.github.com serves the repos of github.com
+		e[strings.TrimPrefix(parsed.Host, "api.")] = u
+	}
+
+	return e, nil
+}
+
+// isGithub reports whether the host is github.com or has a configured API URL
+func (e endpoints) isGithub(host string) bool {
+	_, ok := e[host]
+
+	return ok || host == "github.com"
+}
+
+// apiURL returns the API URL of a host. Only github.com and the hosts with a
+// configured API URL are allowed, the host comes from the repository URL of
+// the action and any other host would receive the credentials.
+func (e endpoints) apiURL(host string) (string, error) {
+	if u, ok := e[host]; ok {
+		return u, nil
+	}
+
+	if host == "" || host == "github.com" {
+		return defaultAPIURL, nil
+	}
+
+	return "", fmt.Errorf("no Github API URL is configured for %s, set it with --github-url or GITHUB_API_URL", host)
+}
+
+func newClient(baseURL string, transport http.RoundTripper) (*github.Client, error) {
+	httpClient := &http.Client{Transport: transport}
+
+	if baseURL == defaultAPIURL {
+		return github.NewClient(httpClient), nil
+	}
+
+	uploadURL := baseURL
+	if strings.HasSuffix(baseURL, "/api/v3/") {
+		uploadURL = strings.TrimSuffix(baseURL, "v3/") + "uploads/"
+	}
+
+	gh, err := github.NewEnterpriseClient(baseURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Github API URL %s: %v", baseURL, err)
+	}
+
+	return gh, nil
+}
+
+type tokenAuthenticator struct {
+	token     string
+	endpoints endpoints
+
+	mu sync.Mutex
+	// clients are the clients of each host
+	clients map[string]*github.Client
+}
+
+func (t *tokenAuthenticator) client(_ context.Context, host, _ string) (*github.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if gh, ok := t.clients[host]; ok {
+		return gh, nil
+	}
+
+	baseURL, err := t.endpoints.apiURL(host)
+	if err != nil {
+		return nil, err
+	}
+
+	gh, err := newClient(baseURL, &tokenTransport{token: t.token, base: http.DefaultTransport})
+	if err != nil {
+		return nil, err
+	}
+
+	t.clients[host] = gh
+
+	return gh, nil
+}
+
+// tokenTransport authenticates requests with a personal access token or a
+// Github Actions token
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+
+	return t.base.RoundTrip(req)
+}
+
+// appAuthenticator authenticates as the installations of a Github app. The
+// installation of each owner is looked up with the app's JWT, so that one app
+// can publish to several organizations, unless GITHUB_APP_INSTALLATION_ID
+// sets the installation, which then has to belong to the owner.
+type appAuthenticator struct {
+	appID          int64
+	key            []byte
+	installationID int64
+	endpoints      endpoints
+
+	mu    sync.Mutex
+	hosts map[string]*appHost
+}
+
+// appHost holds the clients of the app on a host
+type appHost struct {
+	baseURL   string
+	transport *ghinstallation.AppsTransport
+	// apps is authenticated as the app itself, to look up installations
+	apps *github.Client
+	// clients are the installation clients of each owner, by lowercase login
+	clients map[string]*github.Client
+}
+
+func newAppAuthenticator(endpoints endpoints) (*appAuthenticator, error) {
+	appID, err := strconv.ParseInt(os.Getenv("GITHUB_APP_ID"), 10, 64)
+	if err != nil {
+		return nil, errors.New("the Github app ID should be a number")
+	}
+
+	var key []byte
+
+	switch {
+	case os.Getenv("GITHUB_APP_PRIVATE_KEY") != "":
+		key = []byte(strings.ReplaceAll(os.Getenv("GITHUB_APP_PRIVATE_KEY"), "\\n", "\n"))
+	case os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH") != "":
+		key, err = os.ReadFile(os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH"))
+		if err != nil {
+			return nil, fmt.Errorf("could not read the Github app's private key: %v", err)
+		}
+	default:
+		return nil, errors.New("set your Github app's private key as GITHUB_APP_PRIVATE_KEY, or its path as GITHUB_APP_PRIVATE_KEY_PATH")
+	}
+
+	auth := &appAuthenticator{
+		appID:     appID,
+		key:       key,
+		endpoints: endpoints,
+		hosts:     make(map[string]*appHost),
+	}
+
+	if id := os.Getenv("GITHUB_APP_INSTALLATION_ID"); id != "" {
+		auth.installationID, err = strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, errors.New("the Github app installation ID should be a number")
+		}
+	}
+
+	// fail early on an invalid key
+	if _, err := ghinstallation.NewAppsTransport(http.DefaultTransport, appID, key); err != nil {
+		return nil, fmt.Errorf("could not authenticate with Github: %v", err)
+	}
+
+	return auth, nil
+}
+
+func (a *appAuthenticator) client(ctx context.Context, host, owner string) (*github.Client, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	h, err := a.host(host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Github logins are case insensitive
+	key := strings.ToLower(owner)
+
+	if gh, ok := h.clients[key]; ok {
+		return gh, nil
+	}
+
+	installationID := a.installationID
+	if installationID == 0 {
+		installation, err := findInstallation(ctx, h.apps, owner)
+		if err != nil {
+			return nil, err
+		}
+
+		installationID = installation.GetID()
+	} else if err := checkInstallation(ctx, h.apps, installationID, owner); err != nil {
+		return nil, err
+	}
+
+	gh, err := newClient(h.baseURL, ghinstallation.NewFromAppsTransport(h.transport, installationID))
+	if err != nil {
+		return nil, err
+	}
+
+	h.clients[key] = gh
+
+	return gh, nil
+}
+
+// host returns the clients of a host, a.mu must be held. Every host has its
+// own transport, as installation transports change the base URL of the apps
+// transport.
+func (a *appAuthenticator) host(host string) (*appHost, error) {
+	if h, ok := a.hosts[host]; ok {
+		return h, nil
+	}
+
+	atr, err := ghinstallation.NewAppsTransport(http.DefaultTransport, a.appID, a.key)
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate with Github: %v", err)
+	}
+
+	baseURL, err := a.endpoints.apiURL(host)
+	if err != nil {
+		return nil, err
+	}
+
+	atr.BaseURL = strings.TrimSuffix(baseURL, "/")
+
+	apps, err := newClient(baseURL, atr)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &appHost{
+		baseURL:   baseURL,
+		transport: atr,
+		apps:      apps,
+		clients:   make(map[string]*github.Client),
+	}
+
+	a.hosts[host] = h
+
+	return h, nil
+}
+
+// checkInstallation verifies that the installation of GITHUB_APP_INSTALLATION_ID
+// is the one of the owner, instead of using it for the repos of another owner
+func checkInstallation(ctx context.Context, apps *github.Client, installationID int64, owner string) error {
+	installation, _, err := apps.Apps.GetInstallation(ctx, installationID)
+	if err != nil {
+		return fmt.Errorf("could not get the Github app installation %d: %v", installationID, err)
+	}
+
+	if login := installation.GetAccount().GetLogin(); !strings.EqualFold(login, owner) {
+		return fmt.Errorf("GITHUB_APP_INSTALLATION_ID %d is the installation of %s, not of %s: unset it to look up the installation of each owner", installationID, login, owner)
+	}
+
+	return nil
+}
+
+// findInstallation looks up the installation of the app on an organization
+// or a user account
+func findInstallation(ctx context.Context, apps *github.Client, owner string) (*github.Installation, error) {
+	installation, resp, err := apps.Apps.FindOrganizationInstallation(ctx, owner)
+	if err == nil {
+		return installation, nil
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("could not find the installation of the Github app for %s: %v", owner, err)
+	}
+
+	installation, _, err = apps.Apps.FindUserInstallation(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("the Github app is not installed for %s: %v", owner, err)
+	}
+
+	return installation, nil
+}
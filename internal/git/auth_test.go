@@ -0,0 +1,75 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointsAPIURL(t *testing.T) {
+	t.Setenv("GITHUB_API_URL", "")
+
+	endpoints, err := newEndpoints([]string{"https://github.example.com/api/v3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{host: "github.com", want: defaultAPIURL},
+		{host: "github.example.com", want: "https://github.example.com/api/v3/"},
+		{host: "attacker.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := endpoints.apiURL(tt.host)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckInstallation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/app/installations/1" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1, "account": {"login": "Example-Org"}}`))
+	}))
+	defer server.Close()
+
+	apps, err := newClient(server.URL+"/api/v3/", http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkInstallation(context.Background(), apps, 1, "example-org"); err != nil {
+		t.Errorf("expected the installation of example-org, got %v", err)
+	}
+
+	if err := checkInstallation(context.Background(), apps, 1, "other-org"); err == nil {
+		t.Error("expected an error for the repos of another owner")
+	}
+}
@@ -0,0 +1,499 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/gravitational/gamma/internal/action"
+)
+
+const (
+	// BackendGithub deploys with the Git Data API of Github
+	BackendGithub = "github"
+	// BackendGit deploys by cloning and pushing to the target repo with git,
+	// which works with any git server
+	BackendGit = "git"
+)
+
+// gitBackend deploys actions with git instead of the Github API. Target repos
+// are cloned in memory.
+type gitBackend struct {
+	*git
+	// endpoints are the Github hosts, which get the Github token
+	endpoints endpoints
+}
+
+// cloneURL returns the URL of the target repo of the action, without the
+// git+ prefix of npm
+func cloneURL(a action.Action) string {
+	return strings.TrimPrefix(a.RepositoryURL(), "git+")
+}
+
+// auth authenticates http URLs of Github hosts with the token of GITHUB_TOKEN
+// or GH_TOKEN and other hosts with GIT_TOKEN. Tokens are never sent in clear
+// text. Other URLs use the defaults of go-git, e.g. the ssh agent.
+func (g *gitBackend) auth(u string) (transport.AuthMethod, error) {
+	uri, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL %s: %v", u, err)
+	}
+
+	// credentials in the URL are used as they are
+	if (uri.Scheme != "http" && uri.Scheme != "https") || uri.User != nil {
+		return nil, nil
+	}
+
+	names := []string{"GIT_TOKEN"}
+	if g.endpoints.isGithub(uri.Host) {
+		names = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	}
+
+	var token string
+	for _, name := range names {
+		if token = os.Getenv(name); token != "" {
+			break
+		}
+	}
+
+	if token == "" {
+		return nil, nil
+	}
+
+	if uri.Scheme == "http" {
+		return nil, fmt.Errorf("refusing to send %s over http to %s, use https", strings.Join(names, " or "), uri.Host)
+	}
+
+	username := os.Getenv("GIT_USERNAME")
+	if username == "" {
+		username = "x-access-token"
+	}
+
+	return &githttp.BasicAuth{Username: username, Password: token}, nil
+}
+
+func (g *gitBackend) Target(a action.Action) string {
+	return cloneURL(a)
+}
+
+func (g *gitBackend) TagExists(a action.Action) (bool, error) {
+	u := cloneURL(a)
+
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{u},
+	})
+
+	auth, err := g.auth(u)
+	if err != nil {
+		return false, err
+	}
+
+	refs, err := remote.ListContext(context.Background(), &gogit.ListOptions{Auth: auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("could not fetch tags: %v", err)
+	}
+
+	name := plumbing.NewTagReferenceName(a.Tag())
+	for _, ref := range refs {
+		if ref.Name() == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// clone fetches the branch of the target repo, with a worktree when files
+// are to be committed. A branch that doesn't exist yet is created on top of
+// the default branch, which is reported as created. In an empty target repo
+// the branch is created without commits.
+func (g *gitBackend) clone(ctx context.Context, a action.Action, branch plumbing.ReferenceName, checkout bool) (*gogit.Repository, bool, error) {
+	target, err := g.cloneBranch(ctx, a, branch, checkout)
+	if err == nil {
+		return target, false, nil
+	}
+
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		target, err := g.initEmpty(a, branch, checkout)
+		if err != nil {
+			return nil, false, fmt.Errorf("could not prepare the empty repo %s: %v", cloneURL(a), err)
+		}
+
+		return target, true, nil
+	}
+
+	if !errors.Is(err, gogit.NoMatchingRefSpecError{}) {
+		return nil, false, fmt.Errorf("could not clone %s: %v", cloneURL(a), err)
+	}
+
+	target, err = g.cloneBranch(ctx, a, "", checkout)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not clone %s: %v", cloneURL(a), err)
+	}
+
+	head, err := target.Head()
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get the default branch of %s: %v", cloneURL(a), err)
+	}
+
+	if err := target.Storer.SetReference(plumbing.NewHashReference(branch, head.Hash())); err != nil {
+		return nil, false, fmt.Errorf("could not create %s: %v", branch, err)
+	}
+
+	return target, true, nil
+}
+
+// initEmpty creates the local copy of an empty target repo, where the first
+// commit is made on the branch
+func (g *gitBackend) initEmpty(a action.Action, branch plumbing.ReferenceName, checkout bool) (*gogit.Repository, error) {
+	var worktree billy.Filesystem
+	if checkout {
+		worktree = memfs.New()
+	}
+
+	target, err := gogit.Init(memory.NewStorage(), worktree)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = target.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{cloneURL(a)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := target.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch)); err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// cloneBranch clones a single branch of the target repo, the default branch
+// when empty
+func (g *gitBackend) cloneBranch(ctx context.Context, a action.Action, branch plumbing.ReferenceName, checkout bool) (*gogit.Repository, error) {
+	var worktree billy.Filesystem
+	if checkout {
+		worktree = memfs.New()
+	}
+
+	u := cloneURL(a)
+
+	auth, err := g.auth(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return gogit.CloneContext(ctx, memory.NewStorage(), worktree, &gogit.CloneOptions{
+		URL:           u,
+		Auth:          auth,
+		ReferenceName: branch,
+		SingleBranch:  true,
+		Tags:          gogit.NoTags,
+	})
+}
+
+func (g *gitBackend) PlanDeploy(a action.Action, opts DeployOptions) (*DeployPlan, error) {
+	ctx := context.Background()
+
+	branch, err := g.targetBranch(a)
+	if err != nil {
+		return nil, err
+	}
+
+	target, created, err := g.clone(ctx, a, branch, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.plan(a, opts, target, branch, created)
+}
+
+func (g *gitBackend) plan(a action.Action, opts DeployOptions, target *gogit.Repository, branch plumbing.ReferenceName, created bool) (*DeployPlan, error) {
+	current, files, err := branchFiles(target, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DeployPlan{
+		Ref:       branch.String(),
+		NewBranch: created,
+	}
+
+	if current != nil {
+		plan.Parent = current.Hash.String()
+	}
+
+	if opts.PushTags {
+		// make sure tag doesn't already exist
+		tagExists, err := g.TagExists(a)
+		if err != nil {
+			return nil, fmt.Errorf("could not verify if tag exists: %v", err)
+		}
+
+		if tagExists {
+			return nil, fmt.Errorf("tag already exists: %s", a.Tag())
+		}
+
+		plan.Tag = a.Tag()
+	}
+
+	plan.Entries, err = planEntries(a, files, opts.Preserve)
+	if err != nil {
+		return nil, fmt.Errorf("could not create git tree: %v", err)
+	}
+
+	c, err := g.headCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Message = c.Message
+
+	return plan, nil
+}
+
+// branchFiles returns the last commit of a branch of the target repo and its
+// files, no commit when the target repo is empty
+func branchFiles(target *gogit.Repository, branch plumbing.ReferenceName) (*object.Commit, []targetFile, error) {
+	ref, err := target.Reference(branch, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get %s: %v", branch, err)
+	}
+
+	commit, err := target.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get the commit of %s: %v", branch, err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get the tree of %s: %v", branch, err)
+	}
+
+	var files []targetFile
+
+	// unlike tree.Files, the walker also returns submodules
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not list the files of %s: %v", branch, err)
+		}
+
+		typ := "blob"
+		switch entry.Mode {
+		case filemode.Dir:
+			typ = "tree"
+		case filemode.Submodule:
+			typ = "commit"
+		}
+
+		files = append(files, targetFile{
+			path: name,
+			mode: fmt.Sprintf("%o", uint32(entry.Mode)),
+			typ:  typ,
+			sha:  entry.Hash.String(),
+		})
+	}
+
+	return commit, files, nil
+}
+
+func (g *gitBackend) DeployAction(a action.Action, opts DeployOptions) error {
+	ctx := context.Background()
+
+	branch, err := g.targetBranch(a)
+	if err != nil {
+		return err
+	}
+
+	target, created, err := g.clone(ctx, a, branch, true)
+	if err != nil {
+		return err
+	}
+
+	plan, err := g.plan(a, opts, target, branch, created)
+	if err != nil {
+		return err
+	}
+
+	worktree, err := target.Worktree()
+	if err != nil {
+		return fmt.Errorf("could not get the worktree of %s: %v", cloneURL(a), err)
+	}
+
+	for _, entry := range plan.Entries {
+		switch entry.Status {
+		case EntryUnchanged:
+			continue
+		case EntryDeleted:
+			// a submodule is checked out as an empty directory, which Remove
+			// would leave in the index
+			if entry.Mode == "160000" {
+				if err := worktree.Filesystem.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("could not delete %s: %v", entry.Path, err)
+				}
+			}
+
+			if _, err := worktree.Remove(entry.Path); err != nil {
+				return fmt.Errorf("could not delete %s: %v", entry.Path, err)
+			}
+
+			continue
+		}
+
+		if err := writeEntry(worktree.Filesystem, entry); err != nil {
+			return fmt.Errorf("could not write %s: %v", entry.Path, err)
+		}
+
+		if _, err := worktree.Add(entry.Path); err != nil {
+			return fmt.Errorf("could not add %s: %v", entry.Path, err)
+		}
+	}
+
+	head, err := g.headCommit()
+	if err != nil {
+		return err
+	}
+
+	signature := &object.Signature{
+		Name:  head.Author.Name,
+		Email: head.Author.Email,
+		When:  time.Now(),
+	}
+
+	commit, err := worktree.Commit(plan.Message, &gogit.CommitOptions{
+		Author:            signature,
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		return fmt.Errorf("could not commit changes: %v", err)
+	}
+
+	// the worktree commits to the checked out branch, which is the default
+	// branch when the target branch is new
+	if err := target.Storer.SetReference(plumbing.NewHashReference(branch, commit)); err != nil {
+		return fmt.Errorf("could not update %s: %v", branch, err)
+	}
+
+	refSpecs := []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", branch, branch))}
+
+	if plan.Tag != "" {
+		_, err := target.CreateTag(plan.Tag, commit, &gogit.CreateTagOptions{
+			Tagger:  signature,
+			Message: fmt.Sprintf("Tag for version %s", a.Version()),
+		})
+		if err != nil {
+			return fmt.Errorf("could not create the tag: %v", err)
+		}
+
+		tag := plumbing.NewTagReferenceName(plan.Tag)
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf("%s:%s", tag, tag)))
+	}
+
+	auth, err := g.auth(cloneURL(a))
+	if err != nil {
+		return err
+	}
+
+	err = target.PushContext(ctx, &gogit.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   refSpecs,
+		Auth:       auth,
+	})
+	if err != nil {
+		return fmt.Errorf("could not push changes: %v", err)
+	}
+
+	return nil
+}
+
+// writeEntry writes a planned file to the worktree, a symlink's content is
+// its target
+func writeEntry(fs billy.Filesystem, entry PlannedEntry) error {
+	// a file can replace a symlink and the other way around
+	if err := fs.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	switch entry.Mode {
+	case "120000":
+		return fs.Symlink(string(entry.content), entry.Path)
+	case "100755":
+		return util.WriteFile(fs, entry.Path, entry.content, 0755)
+	}
+
+	return util.WriteFile(fs, entry.Path, entry.content, 0644)
+}
+
+func (g *gitBackend) DiffAction(a action.Action, preserve []string) ([]Change, error) {
+	branch, err := g.targetBranch(a)
+	if err != nil {
+		return nil, err
+	}
+
+	target, _, err := g.clone(context.Background(), a, branch, false)
+	if err != nil {
+		return nil, err
+	}
+
+	_, files, err := branchFiles(target, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffOutput(a, files, preserve, func(file targetFile) (*File, error) {
+		blob, err := target.BlobObject(plumbing.NewHash(file.sha))
+		if err != nil {
+			return nil, fmt.Errorf("could not get %s from %s: %v", file.path, cloneURL(a), err)
+		}
+
+		r, err := blob.Reader()
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s from %s: %v", file.path, cloneURL(a), err)
+		}
+		defer r.Close()
+
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s from %s: %v", file.path, cloneURL(a), err)
+		}
+
+		return newFile(file.path, file.mode, content)
+	})
+}
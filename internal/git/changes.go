@@ -0,0 +1,335 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+
+	"github.com/gravitational/gamma/internal/action"
+)
+
+// LastTag can be passed as the base revision to compare against the most
+// recent tag reachable from head, like `git describe --tags`. ChangedActions
+// only considers the tags of each action.
+const LastTag = "last-tag"
+
+// ChangedActions returns the actions with files touched by any commit in the
+// range base..head. An empty head defaults to HEAD and an empty base to the
+// first parent of head. With LastTag, each action is compared against its own
+// most recent tag, the tags matching its tag format, so that the tags of other
+// actions don't hide its changes. If no tag can be found, the whole history of
+// head is considered.
+func (g *git) ChangedActions(actions []action.Action, base, head string) ([]action.Action, error) {
+	headCommit, err := g.resolveHead(head)
+	if err != nil {
+		return nil, err
+	}
+
+	// actions with the same base share their changed files
+	changes := make(map[plumbing.Hash][]string)
+
+	var changed []action.Action
+
+	for _, a := range actions {
+		baseCommit, err := g.resolveBase(base, headCommit, a.TagPattern())
+		if err != nil {
+			return nil, err
+		}
+
+		// plumbing.ZeroHash stands for the whole history
+		var hash plumbing.Hash
+		if baseCommit != nil {
+			hash = baseCommit.Hash
+		}
+
+		files, ok := changes[hash]
+		if !ok {
+			if files, err = g.changedFiles(baseCommit, headCommit); err != nil {
+				return nil, err
+			}
+
+			changes[hash] = files
+		}
+
+		for _, file := range files {
+			if a.Contains(file) {
+				changed = append(changed, a)
+
+				break
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+func (g *git) resolveHead(head string) (*object.Commit, error) {
+	if head == "" {
+		head = "HEAD"
+	}
+
+	return g.resolveCommit(head)
+}
+
+// resolveBase returns the commit changes are detected from, nil for the whole
+// history of head. The LastTag base only considers tags matching pattern,
+// every tag when it is empty.
+func (g *git) resolveBase(base string, head *object.Commit, pattern string) (*object.Commit, error) {
+	switch base {
+	case "":
+		if head.NumParents() == 0 {
+			return nil, nil
+		}
+
+		parent, err := head.Parent(0)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, fmt.Errorf("the parent of %s is missing, the clone is probably shallow: fetch at least 2 commits, e.g. fetch-depth: 2 with actions/checkout, or pass --base", head.Hash)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not get the parent commit: %v", err)
+		}
+
+		return parent, nil
+	case LastTag:
+		return g.lastTaggedCommit(head, pattern)
+	}
+
+	return g.resolveCommit(base)
+}
+
+// changedFiles returns the paths touched by the commits of head that aren't
+// ancestors of base
+func (g *git) changedFiles(baseCommit, headCommit *object.Commit) ([]string, error) {
+	excluded := make(map[plumbing.Hash]struct{})
+	if baseCommit != nil {
+		if err := g.collectAncestors(baseCommit, excluded); err != nil {
+			return nil, err
+		}
+	}
+
+	changedFiles := make(map[string]struct{})
+	visited := make(map[plumbing.Hash]struct{})
+	pending := []*object.Commit{headCommit}
+
+	for len(pending) > 0 {
+		commit := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, ok := excluded[commit.Hash]; ok {
+			continue
+		}
+		if _, ok := visited[commit.Hash]; ok {
+			continue
+		}
+		visited[commit.Hash] = struct{}{}
+
+		parents, err := commitParents(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		files, err := commitChanges(commit, parents)
+		if err != nil {
+			return nil, err
+		}
+
+		for file := range files {
+			changedFiles[file] = struct{}{}
+		}
+
+		pending = append(pending, parents...)
+	}
+
+	var files []string
+	for file := range changedFiles {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func (g *git) resolveCommit(rev string) (*object.Commit, error) {
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve revision %s: %v", rev, err)
+	}
+
+	commit, err := g.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("could not get commit %s: %v", rev, err)
+	}
+
+	return commit, nil
+}
+
+// lastTaggedCommit finds the most recent commit reachable from head that has
+// a tag matching pattern pointing at it, or nil if there is none
+func (g *git) lastTaggedCommit(head *object.Commit, pattern string) (*object.Commit, error) {
+	refs, err := g.repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("could not list tags: %v", err)
+	}
+
+	tagged := make(map[plumbing.Hash]struct{})
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if pattern != "" {
+			if ok, _ := path.Match(pattern, ref.Name().Short()); !ok {
+				return nil
+			}
+		}
+
+		hash := ref.Hash()
+
+		// annotated tags point at a tag object rather than at the commit
+		if tag, err := g.repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// tags of trees or blobs can't be a base
+				return nil
+			}
+
+			hash = commit.Hash
+		}
+
+		tagged[hash] = struct{}{}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve tags: %v", err)
+	}
+
+	var found *object.Commit
+
+	iter := object.NewCommitIterCTime(head, nil, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		if _, ok := tagged[c.Hash]; ok {
+			found = c
+
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, fmt.Errorf("could not walk the history of %s: %v", head.Hash, err)
+	}
+
+	return found, nil
+}
+
+// collectAncestors adds the commit and all of its ancestors to the set. It
+// stops quietly at the edge of a shallow clone.
+func (g *git) collectAncestors(commit *object.Commit, set map[plumbing.Hash]struct{}) error {
+	pending := []plumbing.Hash{commit.Hash}
+
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, ok := set[hash]; ok {
+			continue
+		}
+
+		c, err := g.repo.CommitObject(hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not get commit %s: %v", hash, err)
+		}
+
+		set[hash] = struct{}{}
+		pending = append(pending, c.ParentHashes...)
+	}
+
+	return nil
+}
+
+func commitParents(commit *object.Commit) ([]*object.Commit, error) {
+	var parents []*object.Commit
+
+	for _, hash := range commit.ParentHashes {
+		parent, err := commit.Parent(len(parents))
+		if err != nil {
+			return nil, fmt.Errorf("could not get parent %s of commit %s, the history may be too shallow: %v", hash, commit.Hash, err)
+		}
+
+		parents = append(parents, parent)
+	}
+
+	return parents, nil
+}
+
+// commitChanges returns the paths changed by a commit. A root commit changes
+// every file it contains. A merge commit only changes the paths that differ
+// from all of its parents, as everything else comes from the merged commits.
+func commitChanges(commit *object.Commit, parents []*object.Commit) (map[string]struct{}, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("could not get the tree of commit %s: %v", commit.Hash, err)
+	}
+
+	if len(parents) == 0 {
+		return diffTrees(&object.Tree{}, tree)
+	}
+
+	var result map[string]struct{}
+
+	for _, parent := range parents {
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("could not get the tree of commit %s: %v", parent.Hash, err)
+		}
+
+		files, err := diffTrees(parentTree, tree)
+		if err != nil {
+			return nil, fmt.Errorf("could not diff commit %s against %s: %v", commit.Hash, parent.Hash, err)
+		}
+
+		if result == nil {
+			result = files
+
+			continue
+		}
+
+		for file := range result {
+			if _, ok := files[file]; !ok {
+				delete(result, file)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func diffTrees(from, to *object.Tree) (map[string]struct{}, error) {
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]struct{})
+
+	for _, change := range changes {
+		// renames report both the old and the new path
+		if change.From.Name != "" {
+			files[change.From.Name] = struct{}{}
+		}
+		if change.To.Name != "" {
+			files[change.To.Name] = struct{}{}
+		}
+	}
+
+	return files, nil
+}
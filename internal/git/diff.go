@@ -0,0 +1,185 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+
+	"github.com/gravitational/gamma/internal/action"
+)
+
+// Change is a file that differs between the build output of an action and
+// the branch of its target repo
+type Change struct {
+	// From is the file in the target repo, nil when the deploy adds it
+	From *File
+	// To is the file of the build output, nil when the deploy deletes it
+	To *File
+}
+
+// Path returns the path of the changed file in the target repo
+func (c Change) Path() string {
+	if c.To != nil {
+		return c.To.path
+	}
+
+	return c.From.path
+}
+
+// File is a version of a changed file
+type File struct {
+	path    string
+	mode    filemode.FileMode
+	hash    plumbing.Hash
+	content []byte
+}
+
+func newFile(p, mode string, content []byte) (*File, error) {
+	m, err := filemode.New(mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mode %s of %s: %v", mode, p, err)
+	}
+
+	return &File{
+		path:    p,
+		mode:    m,
+		hash:    plumbing.ComputeHash(plumbing.BlobObject, content),
+		content: content,
+	}, nil
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+func (f *File) Mode() filemode.FileMode {
+	return f.mode
+}
+
+func (f *File) Hash() plumbing.Hash {
+	return f.hash
+}
+
+func (f *File) Content() []byte {
+	return f.content
+}
+
+// DiffAction compares the build output of the action with the branch it is
+// deployed to. Files of the target repo that are preserved aren't reported
+// as deleted.
+func (g *git) DiffAction(a action.Action, preserve []string) ([]Change, error) {
+	ctx := context.Background()
+
+	tree, err := g.getTree(a)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the tree of %s/%s: %v", a.Owner(), a.RepoName(), err)
+	}
+
+	return diffOutput(a, githubFiles(tree), preserve, func(file targetFile) (*File, error) {
+		return g.getFile(ctx, a, file)
+	})
+}
+
+// diffOutput compares the build output of the action with the files of the
+// target repo, read downloads a file of the target repo
+func diffOutput(a action.Action, current []targetFile, preserve []string, read func(targetFile) (*File, error)) ([]Change, error) {
+	remote := make(map[string]targetFile)
+	for _, file := range current {
+		if file.isFile() {
+			remote[file.path] = file
+		}
+	}
+
+	// submodules have no blob to read, git shows the commit they point to
+	readFile := func(file targetFile) (*File, error) {
+		if file.typ == "commit" {
+			f, err := newFile(file.path, file.mode, []byte(fmt.Sprintf("Subproject commit %s\n", file.sha)))
+			if err != nil {
+				return nil, err
+			}
+
+			f.hash = plumbing.NewHash(file.sha)
+
+			return f, nil
+		}
+
+		return read(file)
+	}
+
+	var changes []Change
+
+	err := walkOutput(a, func(filename, p string, info os.FileInfo) error {
+		content, mode, err := readOutputFile(filename, info)
+		if err != nil {
+			return err
+		}
+
+		to, err := newFile(p, mode, content)
+		if err != nil {
+			return err
+		}
+
+		entry, ok := remote[p]
+		if !ok {
+			changes = append(changes, Change{To: to})
+
+			return nil
+		}
+
+		delete(remote, p)
+
+		if entry.sha == to.hash.String() && entry.mode == mode {
+			return nil
+		}
+
+		from, err := readFile(entry)
+		if err != nil {
+			return err
+		}
+
+		changes = append(changes, Change{From: from, To: to})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for p, entry := range remote {
+		if isPreserved(p, preserve) {
+			continue
+		}
+
+		from, err := readFile(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, Change{From: from})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path() < changes[j].Path()
+	})
+
+	return changes, nil
+}
+
+// getFile downloads a file of the target repo
+func (g *git) getFile(ctx context.Context, a action.Action, file targetFile) (*File, error) {
+	gh, err := g.client(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	content, _, err := gh.Git.GetBlobRaw(ctx, a.Owner(), a.RepoName(), file.sha)
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s from %s/%s: %v", file.path, a.Owner(), a.RepoName(), err)
+	}
+
+	return newFile(file.path, file.mode, content)
+}
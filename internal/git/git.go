@@ -2,179 +2,381 @@ package git
 
 import (
 	"context"
-	"errors"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/bradleyfalzon/ghinstallation/v2"
 	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/google/go-github/v48/github"
 
 	"github.com/gravitational/gamma/internal/action"
 )
 
 type Git interface {
-	GetChangedFiles() ([]string, error)
+	ChangedActions(actions []action.Action, base, head string) ([]action.Action, error)
 	TagExists(a action.Action) (bool, error)
-	DeployAction(a action.Action, pushTags bool) error
+	DeployAction(a action.Action, opts DeployOptions) error
+	PlanDeploy(a action.Action, opts DeployOptions) (*DeployPlan, error)
+	DiffAction(a action.Action, preserve []string) ([]Change, error)
+	// Target describes the target repo of the action in messages
+	Target(a action.Action) string
+}
+
+type DeployOptions struct {
+	// PushTags creates the version tag of the action
+	PushTags bool
+	// Preserve lists paths in the target repo that are kept even when they
+	// are not part of the build output. Paths ending in a slash match a
+	// whole directory, anything else is matched as a glob.
+	Preserve []string
 }
 
 type git struct {
 	repo *gogit.Repository
-	gh   *github.Client
+	auth authenticator
+}
+
+// Config configures how target repos are reached
+type Config struct {
+	// APIURLs are base URLs of Github APIs, used for the repos of their host
+	// instead of the URL derived from the host
+	APIURLs []string
+	// Backend is how actions are deployed, BackendGithub when empty
+	Backend string
 }
 
-func New(wd string) (Git, error) {
+func New(wd string, config Config) (Git, error) {
 	repo, err := gogit.PlainOpen(wd)
 	if err != nil {
 		return nil, fmt.Errorf("the current directory is not a git repo: %v", err)
 	}
 
-	gh, err := createGithubClient()
+	switch config.Backend {
+	case "", BackendGithub:
+	case BackendGit:
+		// target repos are reached with git, without the Github API
+		endpoints, err := newEndpoints(config.APIURLs)
+		if err != nil {
+			return nil, err
+		}
+
+		return &gitBackend{git: &git{repo: repo}, endpoints: endpoints}, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %s, expected %s or %s", config.Backend, BackendGithub, BackendGit)
+	}
+
+	auth, err := newAuthenticator(config.APIURLs)
 	if err != nil {
 		return nil, err
 	}
 
-	return &git{repo, gh}, nil
+	return &git{repo, auth}, nil
 }
 
-func createGithubClient() (*github.Client, error) {
-	if os.Getenv("GITHUB_APP_PRIVATE_KEY") == "" {
-		return nil, errors.New("set your Github app's private key as GITHUB_APP_PRIVATE_KEY")
+// client returns the Github client for the target repo of the action
+func (g *git) client(ctx context.Context, a action.Action) (*github.Client, error) {
+	if a.Owner() == "" || a.RepoName() == "" {
+		return nil, fmt.Errorf("invalid repository URL %s, expected https://github.com/owner/repo", a.RepositoryURL())
 	}
 
-	privateKey := strings.ReplaceAll(os.Getenv("GITHUB_APP_PRIVATE_KEY"), "\\n", "\n")
+	return g.auth.client(ctx, a.Host(), a.Owner())
+}
 
-	if os.Getenv("GITHUB_APP_ID") == "" {
-		return nil, errors.New("set your Github app's ID as GITHUB_APP_ID")
-	}
+func (g *git) Target(a action.Action) string {
+	return fmt.Sprintf("%s/%s", a.Owner(), a.RepoName())
+}
 
-	appID, err := strconv.Atoi(os.Getenv("GITHUB_APP_ID"))
+func (g *git) TagExists(a action.Action) (bool, error) {
+	ctx := context.Background()
+
+	gh, err := g.client(ctx, a)
 	if err != nil {
-		return nil, errors.New("the Github app ID should be a number")
+		return false, err
+	}
+
+	_, resp, err := gh.Git.GetRef(ctx, a.Owner(), a.RepoName(), "refs/tags/"+a.Tag())
+	if err == nil {
+		return true, nil
 	}
 
-	if os.Getenv("GITHUB_APP_INSTALLATION_ID") == "" {
-		return nil, errors.New("set your Github app's installation ID as GITHUB_APPID")
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
 	}
 
-	appInstallationID, err := strconv.Atoi(os.Getenv("GITHUB_APP_INSTALLATION_ID"))
+	return false, fmt.Errorf("could not fetch tag %s: %v", a.Tag(), err)
+}
+
+// DeployPlan is what deploying an action pushes to its target repo
+type DeployPlan struct {
+	// Ref is the branch the commit is pushed to
+	Ref string
+	// Parent is the commit the new commit is created on top of, empty when
+	// the target repo has no commits yet
+	Parent string
+	// NewBranch is set when the branch doesn't exist yet, it is then created
+	// on top of the default branch
+	NewBranch bool
+	Message   string
+	// Tag is created on the new commit, empty when tags aren't pushed
+	Tag     string
+	Entries []PlannedEntry
+
+	ref *github.Reference
+}
+
+const (
+	EntryAdded     = "added"
+	EntryModified  = "modified"
+	EntryUnchanged = "unchanged"
+	EntryDeleted   = "deleted"
+)
+
+// PlannedEntry is a file of the tree of a deploy
+type PlannedEntry struct {
+	Path string
+	Mode string
+	// Status is how the file changes in the target repo
+	Status string
+
+	typ     string
+	content []byte
+	// sha is the blob of the target repo an unchanged file keeps
+	sha string
+}
+
+func (g *git) DeployAction(a action.Action, opts DeployOptions) error {
+	plan, err := g.PlanDeploy(a, opts)
 	if err != nil {
-		return nil, errors.New("the Github app installation ID should be a number")
+		return err
 	}
 
-	itr, err := ghinstallation.New(http.DefaultTransport, int64(appID), int64(appInstallationID), []byte(privateKey))
+	tree, err := g.uploadTree(context.Background(), a, plan)
 	if err != nil {
-		return nil, fmt.Errorf("could not authenticate with Github: %v", err)
+		return fmt.Errorf("could not create git tree: %v", err)
+	}
+
+	newCommit, err := g.pushCommit(context.Background(), plan, tree, a)
+	if err != nil {
+		return fmt.Errorf("could not push changes: %v", err)
+	}
+
+	if plan.Tag != "" {
+		if err := g.pushTag(context.Background(), a, plan.Tag, newCommit); err != nil {
+			return fmt.Errorf("could not push tag: %v", err)
+		}
 	}
 
-	return github.NewClient(&http.Client{Transport: itr}), nil
+	return nil
 }
 
-func (g *git) TagExists(a action.Action) (bool, error) {
-	tags, _, err := g.gh.Repositories.ListTags(context.Background(), a.Owner(), a.RepoName(), nil)
+// PlanDeploy computes the commit and tag deploying the action would create,
+// without changing the target repo
+func (g *git) PlanDeploy(a action.Action, opts DeployOptions) (*DeployPlan, error) {
+	ctx := context.Background()
+
+	ref, created, err := g.getRef(ctx, a)
 	if err != nil {
-		return false, fmt.Errorf("could not fetch tags: %v", err)
+		return nil, fmt.Errorf("could not create git ref: %v", err)
 	}
 
-	// iterate over all tags, return true if the tag exists
-	for _, t := range tags {
-		if *t.Name == fmt.Sprintf("v%s", a.Version()) {
-			return true, nil
+	plan := &DeployPlan{
+		Ref:       ref.GetRef(),
+		Parent:    ref.GetObject().GetSHA(),
+		NewBranch: created,
+		ref:       ref,
+	}
+
+	if opts.PushTags {
+		// make sure tag doesn't already exist
+		tagExists, err := g.TagExists(a)
+		if err != nil {
+			return nil, fmt.Errorf("could not verify if tag exists: %v", err)
 		}
+
+		if tagExists {
+			return nil, fmt.Errorf("tag already exists: %s", a.Tag())
+		}
+
+		plan.Tag = a.Tag()
 	}
 
-	return false, nil
+	plan.Entries, err = g.planTree(ctx, ref, a, opts.Preserve)
+	if err != nil {
+		return nil, fmt.Errorf("could not create git tree: %v", err)
+	}
+
+	c, err := g.headCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Message = c.Message
+
+	return plan, nil
 }
 
-func (g *git) GetChangedFiles() ([]string, error) {
+// headCommit returns the HEAD commit of the monorepo, whose message is used
+// for the deploy commits
+func (g *git) headCommit() (*object.Commit, error) {
 	head, err := g.repo.Head()
 	if err != nil {
 		return nil, fmt.Errorf("could not get HEAD: %v", err)
 	}
 
-	commit, err := g.repo.CommitObject(head.Hash())
+	c, err := g.repo.CommitObject(head.Hash())
 	if err != nil {
 		return nil, fmt.Errorf("could not get the HEAD commit: %v", err)
 	}
 
-	parentHash := commit.ParentHashes[0]
-	parent, err := g.repo.CommitObject(parentHash)
-	if err != nil {
-		return nil, fmt.Errorf("could not get the parent commit: %v", err)
+	return c, nil
+}
+
+// targetFile is a file of the branch of a target repo
+type targetFile struct {
+	path string
+	mode string
+	typ  string
+	sha  string
+}
+
+// isFile reports whether the entry is deployed as a file, which includes
+// symlinks and submodules. Trees disappear with their last file.
+func (f targetFile) isFile() bool {
+	return f.typ != "tree"
+}
+
+func githubFiles(tree *github.Tree) []targetFile {
+	var files []targetFile
+	for _, entry := range tree.Entries {
+		files = append(files, targetFile{
+			path: entry.GetPath(),
+			mode: entry.GetMode(),
+			typ:  entry.GetType(),
+			sha:  entry.GetSHA(),
+		})
 	}
 
-	patch, err := parent.Patch(commit)
+	return files
+}
+
+// planTree lists the files of the build output and the files of the target
+// repo they replace
+func (g *git) planTree(ctx context.Context, ref *github.Reference, a action.Action, preserve []string) ([]PlannedEntry, error) {
+	current, err := g.getTargetTree(ctx, a, *ref.Object.SHA)
 	if err != nil {
-		return nil, fmt.Errorf("could not get the parent patch: %v", err)
+		return nil, err
 	}
 
-	changedFiles := make(map[string]struct{})
-
-	for _, p := range patch.FilePatches() {
-		from, to := p.Files()
+	return planEntries(a, githubFiles(current), preserve)
+}
 
-		if from != nil {
-			changedFiles[from.Path()] = struct{}{}
-		}
-		if to != nil {
-			changedFiles[to.Path()] = struct{}{}
+// planEntries compares the build output of the action with the files of the
+// target repo
+func planEntries(a action.Action, current []targetFile, preserve []string) ([]PlannedEntry, error) {
+	remote := make(map[string]targetFile)
+	for _, file := range current {
+		if file.isFile() {
+			remote[file.path] = file
 		}
 	}
 
-	var files []string
-	for file := range changedFiles {
-		files = append(files, file)
-	}
+	var entries []PlannedEntry
 
-	return files, nil
-}
+	files := make(map[string]struct{})
 
-func (g *git) DeployAction(a action.Action, pushTags bool) error {
-	ref, err := g.getRef(context.Background(), a)
-	if err != nil {
-		return fmt.Errorf("could not create git ref: %v", err)
-	}
+	ferr := walkOutput(a, func(filename, p string, info os.FileInfo) error {
+		files[p] = struct{}{}
 
-	if pushTags {
-		// make sure tag doesn't already exist
-		tagExists, err := g.TagExists(a)
+		content, mode, err := readOutputFile(filename, info)
 		if err != nil {
-			return fmt.Errorf("could not verify if tag exists: %v", err)
+			return err
 		}
 
-		if tagExists {
-			return fmt.Errorf("tag already exists: v%v", a.Version())
+		status, sha := EntryAdded, ""
+		if entry, ok := remote[p]; ok {
+			status = EntryModified
+
+			hash := plumbing.ComputeHash(plumbing.BlobObject, content)
+			if entry.sha == hash.String() && entry.mode == mode {
+				status, sha = EntryUnchanged, entry.sha
+			}
 		}
-	}
 
-	tree, err := g.getTree(context.Background(), ref, a)
-	if err != nil {
-		return fmt.Errorf("could not create git tree: %v", err)
+		entries = append(entries, PlannedEntry{
+			Path:    p,
+			Mode:    mode,
+			Status:  status,
+			typ:     "blob",
+			content: content,
+			sha:     sha,
+		})
+
+		return nil
+	})
+
+	if ferr != nil {
+		return nil, ferr
 	}
 
-	newCommit, err := g.pushCommit(context.Background(), ref, tree, a)
+	return append(entries, getDeletions(current, files, preserve)...), nil
+}
+
+// uploadTree creates the planned tree in the target repo. Unchanged files
+// reuse their blob in the target repo, other content that isn't valid UTF-8 is
+// uploaded as a base64 encoded blob.
+func (g *git) uploadTree(ctx context.Context, a action.Action, plan *DeployPlan) (*github.Tree, error) {
+	gh, err := g.client(ctx, a)
 	if err != nil {
-		return fmt.Errorf("could not push changes: %v", err)
+		return nil, err
 	}
 
-	if pushTags {
-		if err := g.pushTag(context.Background(), a, newCommit); err != nil {
-			return fmt.Errorf("could not push tag: %v", err)
+	var entries []*github.TreeEntry
+
+	for _, e := range plan.Entries {
+		entry := &github.TreeEntry{
+			Path: github.String(e.Path),
+			Type: github.String(e.typ),
+			Mode: github.String(e.Mode),
+		}
+
+		switch {
+		case e.Status == EntryDeleted:
+			// an entry without SHA or content deletes the path
+		case e.sha != "":
+			entry.SHA = github.String(e.sha)
+		case utf8.Valid(e.content):
+			entry.Content = github.String(string(e.content))
+		default:
+			blob, _, err := gh.Git.CreateBlob(ctx, a.Owner(), a.RepoName(), &github.Blob{
+				Content:  github.String(base64.StdEncoding.EncodeToString(e.content)),
+				Encoding: github.String("base64"),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not create blob for %s: %v", e.Path, err)
+			}
+
+			entry.SHA = blob.SHA
 		}
+
+		entries = append(entries, entry)
 	}
 
-	return nil
-}
+	tree, _, err := gh.Git.CreateTree(ctx, a.Owner(), a.RepoName(), plan.Parent, entries)
 
-func (g *git) getTree(ctx context.Context, ref *github.Reference, a action.Action) (*github.Tree, error) {
-	var entries []*github.TreeEntry
+	return tree, err
+}
 
-	ferr := filepath.Walk(a.OutputDirectory(),
+// walkOutput calls fn for every file of the build output of the action, with
+// its path relative to the output directory
+func walkOutput(a action.Action, fn func(filename, p string, info os.FileInfo) error) error {
+	return filepath.Walk(a.OutputDirectory(),
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -184,82 +386,205 @@ func (g *git) getTree(ctx context.Context, ref *github.Reference, a action.Actio
 				return nil
 			}
 
-			content, err := os.ReadFile(path)
-			if err != nil {
-				return fmt.Errorf("could not read %s: %v", path, err)
-			}
-
 			p, err := filepath.Rel(a.OutputDirectory(), path)
 			if err != nil {
 				return fmt.Errorf("could not resolve relative path between %s and %s: %v", a.OutputDirectory(), path, err)
 			}
 
-			entry := &github.TreeEntry{
-				Path:    github.String(p),
-				Type:    github.String("blob"),
-				Content: github.String(string(content)),
-				Mode:    github.String("100644"),
-			}
+			return fn(path, filepath.ToSlash(p), info)
+		})
+}
 
-			entries = append(entries, entry)
+// readOutputFile returns the content of a file of the build output and its
+// git mode. The content of a symlink is its target.
+func readOutputFile(filename string, info os.FileInfo) ([]byte, string, error) {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(filename)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read link %s: %v", filename, err)
+		}
 
-			return nil
-		})
+		return []byte(filepath.ToSlash(target)), "120000", nil
+	case info.Mode().IsRegular():
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read %s: %v", filename, err)
+		}
 
-	if ferr != nil {
-		return nil, ferr
+		if info.Mode()&0111 != 0 {
+			return content, "100755", nil
+		}
+
+		return content, "100644", nil
 	}
 
-	tree, _, err := g.gh.Git.CreateTree(ctx, a.Owner(), a.RepoName(), *ref.Object.SHA, entries)
+	return nil, "", fmt.Errorf("%s is not a regular file or symlink", filename)
+}
 
-	return tree, err
+// getDeletions returns entries removing every file of the target repo that
+// is neither part of the build output nor preserved
+func getDeletions(current []targetFile, files map[string]struct{}, preserve []string) []PlannedEntry {
+	var entries []PlannedEntry
+
+	for _, file := range current {
+		if !file.isFile() {
+			continue
+		}
+
+		if _, ok := files[file.path]; ok {
+			continue
+		}
+
+		if isPreserved(file.path, preserve) {
+			continue
+		}
+
+		entries = append(entries, PlannedEntry{
+			Path:   file.path,
+			Mode:   file.mode,
+			Status: EntryDeleted,
+			typ:    file.typ,
+		})
+	}
+
+	return entries
 }
 
-func (g *git) getRef(ctx context.Context, a action.Action) (*github.Reference, error) {
-	head, err := g.repo.Head()
+// getTree returns the recursive tree of the branch the action is deployed to
+func (g *git) getTree(a action.Action) (*github.Tree, error) {
+	ctx := context.Background()
+
+	ref, _, err := g.getRef(ctx, a)
 	if err != nil {
-		return nil, fmt.Errorf("could not get HEAD: %v", err)
+		return nil, fmt.Errorf("could not get git ref: %v", err)
 	}
 
-	ref, _, err := g.gh.Git.GetRef(ctx, a.Owner(), a.RepoName(), head.Name().String())
+	return g.getTargetTree(ctx, a, *ref.Object.SHA)
+}
+
+func (g *git) getTargetTree(ctx context.Context, a action.Action, sha string) (*github.Tree, error) {
+	gh, err := g.client(ctx, a)
 	if err != nil {
 		return nil, err
 	}
 
-	return ref, nil
+	tree, _, err := gh.Git.GetTree(ctx, a.Owner(), a.RepoName(), sha, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("the tree of %s/%s is too large to be listed", a.Owner(), a.RepoName())
+	}
+
+	return tree, nil
+}
+
+func isPreserved(file string, preserve []string) bool {
+	for _, p := range preserve {
+		if strings.HasSuffix(p, "/") {
+			if strings.HasPrefix(file, p) {
+				return true
+			}
+
+			continue
+		}
+
+		if ok, _ := path.Match(p, file); ok {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (g *git) pushCommit(ctx context.Context, ref *github.Reference, tree *github.Tree, a action.Action) (*github.Commit, error) {
-	parent, _, err := g.gh.Repositories.GetCommit(ctx, a.Owner(), a.RepoName(), *ref.Object.SHA, nil)
+// getRef returns the branch the action is deployed to. A branch that doesn't
+// exist yet points to the default branch, and is reported as created.
+func (g *git) getRef(ctx context.Context, a action.Action) (*github.Reference, bool, error) {
+	gh, err := g.client(ctx, a)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	parent.Commit.SHA = parent.SHA
+	branch, err := g.targetBranch(a)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ref, resp, err := gh.Git.GetRef(ctx, a.Owner(), a.RepoName(), branch.String())
+	if err == nil {
+		return ref, false, nil
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, false, err
+	}
+
+	repository, _, err := gh.Repositories.Get(ctx, a.Owner(), a.RepoName())
+	if err != nil {
+		return nil, false, err
+	}
+
+	base, _, err := gh.Git.GetRef(ctx, a.Owner(), a.RepoName(), "refs/heads/"+repository.GetDefaultBranch())
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get the default branch: %v", err)
+	}
+
+	return &github.Reference{
+		Ref:    github.String(branch.String()),
+		Object: &github.GitObject{SHA: base.Object.SHA},
+	}, true, nil
+}
+
+// targetBranch returns the branch of the target repo the action is deployed
+// to, by default the branch checked out in the monorepo
+func (g *git) targetBranch(a action.Action) (plumbing.ReferenceName, error) {
+	if a.Branch() != "" {
+		return plumbing.NewBranchReferenceName(a.Branch()), nil
+	}
 
 	head, err := g.repo.Head()
 	if err != nil {
-		return nil, fmt.Errorf("could not get HEAD: %v", err)
+		return "", fmt.Errorf("could not get HEAD: %v", err)
 	}
 
-	c, err := g.repo.CommitObject(head.Hash())
+	return head.Name(), nil
+}
+
+func (g *git) pushCommit(ctx context.Context, plan *DeployPlan, tree *github.Tree, a action.Action) (*github.Commit, error) {
+	gh, err := g.client(ctx, a)
 	if err != nil {
-		return nil, fmt.Errorf("could not get the HEAD commit: %v", err)
+		return nil, err
 	}
 
+	parent, _, err := gh.Repositories.GetCommit(ctx, a.Owner(), a.RepoName(), plan.Parent, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	parent.Commit.SHA = parent.SHA
+
 	commit := &github.Commit{
-		Message: github.String(c.Message),
+		Message: github.String(plan.Message),
 		Tree:    tree,
 		Parents: []*github.Commit{parent.Commit},
 	}
 
-	newCommit, _, err := g.gh.Git.CreateCommit(ctx, a.Owner(), a.RepoName(), commit)
+	newCommit, _, err := gh.Git.CreateCommit(ctx, a.Owner(), a.RepoName(), commit)
 	if err != nil {
 		return nil, err
 	}
 
+	ref := plan.ref
 	ref.Object.SHA = newCommit.SHA
-	_, _, err = g.gh.Git.UpdateRef(ctx, a.Owner(), a.RepoName(), ref, false)
+
+	if plan.NewBranch {
+		_, _, err = gh.Git.CreateRef(ctx, a.Owner(), a.RepoName(), ref)
+	} else {
+		_, _, err = gh.Git.UpdateRef(ctx, a.Owner(), a.RepoName(), ref, false)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -267,21 +592,25 @@ func (g *git) pushCommit(ctx context.Context, ref *github.Reference, tree *githu
 	return newCommit, nil
 }
 
-func (g *git) pushTag(ctx context.Context, a action.Action, newCommit *github.Commit) error {
-	tagString := fmt.Sprintf("v%v", a.Version())
+func (g *git) pushTag(ctx context.Context, a action.Action, tagString string, newCommit *github.Commit) error {
+	gh, err := g.client(ctx, a)
+	if err != nil {
+		return err
+	}
+
 	tag := &github.Tag{
 		Tag:     github.String(tagString),
 		Message: github.String(fmt.Sprintf("Tag for version %s", a.Version())),
 		Object:  &github.GitObject{SHA: github.String(*newCommit.SHA), Type: github.String("commit")},
 	}
 
-	_, _, err := g.gh.Git.CreateTag(ctx, a.Owner(), a.RepoName(), tag)
+	_, _, err = gh.Git.CreateTag(ctx, a.Owner(), a.RepoName(), tag)
 	if err != nil {
 		return fmt.Errorf("could not create the tag: %v", err)
 	}
 
 	refTag := &github.Reference{Ref: github.String("refs/tags/" + tagString), Object: &github.GitObject{SHA: github.String(*newCommit.SHA)}}
-	_, _, err = g.gh.Git.CreateRef(ctx, a.Owner(), a.RepoName(), refTag)
+	_, _, err = gh.Git.CreateRef(ctx, a.Owner(), a.RepoName(), refTag)
 	if err != nil {
 		return fmt.Errorf("could not create the reference for tag: %v", err)
 	}
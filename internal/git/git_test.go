@@ -0,0 +1,111 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-github/v48/github"
+
+	"github.com/gravitational/gamma/internal/action"
+	"github.com/gravitational/gamma/pkg/schema"
+)
+
+type staticAuthenticator struct {
+	gh *github.Client
+}
+
+func (s staticAuthenticator) client(ctx context.Context, host, owner string) (*github.Client, error) {
+	return s.gh, nil
+}
+
+func TestUploadTreeReusesUnchangedBlobs(t *testing.T) {
+	unchanged, modified := "\xff\xfeunchanged", "\xff\xfemodified"
+
+	output := t.TempDir()
+	writeFile(t, filepath.Join(output, "unchanged.bin"), unchanged, 0644)
+	writeFile(t, filepath.Join(output, "modified.bin"), modified, 0644)
+
+	a, err := action.New(&action.Config{
+		Name:             "example",
+		WorkingDirectory: t.TempDir(),
+		OutputDirectory:  output,
+		ActionInfo: &schema.ActionInfo{
+			Name:          "example",
+			Version:       "1.0.0",
+			RepositoryURL: "https://github.com/owner/example",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha := plumbing.ComputeHash(plumbing.BlobObject, []byte(unchanged)).String()
+
+	entries, err := planEntries(a, []targetFile{
+		{path: "unchanged.bin", mode: "100644", typ: "blob", sha: sha},
+		{path: "modified.bin", mode: "100644", typ: "blob", sha: "0000000000000000000000000000000000000001"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blobs int
+	var tree struct {
+		Tree []github.TreeEntry `json:"tree"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api/v3/repos/owner/example/git/blobs":
+			blobs++
+			_, _ = w.Write([]byte(`{"sha": "0000000000000000000000000000000000000002"}`))
+		case "/api/v3/repos/owner/example/git/trees":
+			if err := json.NewDecoder(r.Body).Decode(&tree); err != nil {
+				t.Error(err)
+			}
+			_, _ = w.Write([]byte(`{"sha": "0000000000000000000000000000000000000003"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gh, err := newClient(server.URL+"/api/v3/", http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := &git{auth: staticAuthenticator{gh: gh}}
+
+	if _, err := g.uploadTree(context.Background(), a, &DeployPlan{Parent: "parent", Entries: entries}); err != nil {
+		t.Fatal(err)
+	}
+
+	if blobs != 1 {
+		t.Errorf("expected a blob for the modified file only, got %d", blobs)
+	}
+
+	want := map[string]string{
+		"unchanged.bin": sha,
+		"modified.bin":  "0000000000000000000000000000000000000002",
+	}
+
+	for _, entry := range tree.Tree {
+		if got := entry.GetSHA(); got != want[entry.GetPath()] {
+			t.Errorf("expected %s to be %s, got %s", entry.GetPath(), want[entry.GetPath()], got)
+		}
+
+		delete(want, entry.GetPath())
+	}
+
+	for p := range want {
+		t.Errorf("missing %s in the tree", p)
+	}
+}
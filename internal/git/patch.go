@@ -0,0 +1,191 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// WritePatch writes the changes as a unified diff, prefix is added to the
+// paths of the files
+func WritePatch(w io.Writer, changes []Change, prefix string) error {
+	encoder := fdiff.NewUnifiedEncoder(w, fdiff.DefaultContextLines).
+		SetSrcPrefix("a/" + prefix).
+		SetDstPrefix("b/" + prefix)
+
+	return encoder.Encode(patch(changes))
+}
+
+// maxStatWidth is the longest graph of changed lines written by WriteStat
+const maxStatWidth = 40
+
+// WriteStat writes the number of changed lines of every file, like
+// git diff --stat
+func WriteStat(w io.Writer, changes []Change, prefix string) error {
+	width := 0
+	for _, change := range changes {
+		if l := len(prefix + change.Path()); l > width {
+			width = l
+		}
+	}
+
+	var insertions, deletions int
+
+	for _, change := range changes {
+		name := prefix + change.Path()
+		fp := filePatch{change}
+
+		if fp.IsBinary() {
+			if _, err := fmt.Fprintf(w, " %-*s | Bin %d -> %d bytes\n", width, name, len(fp.content(change.From)), len(fp.content(change.To))); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		var added, deleted int
+		for _, chunk := range fp.Chunks() {
+			switch chunk.Type() {
+			case fdiff.Add:
+				added += countLines(chunk.Content())
+			case fdiff.Delete:
+				deleted += countLines(chunk.Content())
+			}
+		}
+
+		insertions += added
+		deletions += deleted
+
+		plus, minus := added, deleted
+		if total := added + deleted; total > maxStatWidth {
+			plus = added * maxStatWidth / total
+			minus = maxStatWidth - plus
+		}
+
+		if _, err := fmt.Fprintf(w, " %-*s | %d %s%s\n", width, name, added+deleted, strings.Repeat("+", plus), strings.Repeat("-", minus)); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, " %d files changed, %d insertions(+), %d deletions(-)\n", len(changes), insertions, deletions)
+
+	return err
+}
+
+// WriteNameOnly writes the paths of the changed files
+func WriteNameOnly(w io.Writer, changes []Change, prefix string) error {
+	for _, change := range changes {
+		if _, err := fmt.Fprintln(w, prefix+change.Path()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	n := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		n++
+	}
+
+	return n
+}
+
+type patch []Change
+
+func (p patch) FilePatches() []fdiff.FilePatch {
+	var patches []fdiff.FilePatch
+	for _, change := range p {
+		patches = append(patches, filePatch{change})
+	}
+
+	return patches
+}
+
+func (p patch) Message() string {
+	return ""
+}
+
+type filePatch struct {
+	change Change
+}
+
+func (p filePatch) IsBinary() bool {
+	for _, f := range []*File{p.change.From, p.change.To} {
+		if f != nil && (!utf8.Valid(f.content) || bytes.IndexByte(f.content, 0) != -1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Files returns untyped nils for missing files, which the encoder relies on
+func (p filePatch) Files() (fdiff.File, fdiff.File) {
+	var from, to fdiff.File
+
+	if p.change.From != nil {
+		from = p.change.From
+	}
+
+	if p.change.To != nil {
+		to = p.change.To
+	}
+
+	return from, to
+}
+
+func (p filePatch) Chunks() []fdiff.Chunk {
+	if p.IsBinary() {
+		return nil
+	}
+
+	var chunks []fdiff.Chunk
+
+	for _, d := range diff.Do(string(p.content(p.change.From)), string(p.content(p.change.To))) {
+		op := fdiff.Equal
+
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		}
+
+		chunks = append(chunks, chunk{d.Text, op})
+	}
+
+	return chunks
+}
+
+func (p filePatch) content(f *File) []byte {
+	if f == nil {
+		return nil
+	}
+
+	return f.content
+}
+
+type chunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c chunk) Content() string {
+	return c.content
+}
+
+func (c chunk) Type() fdiff.Operation {
+	return c.op
+}
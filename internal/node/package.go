@@ -7,6 +7,8 @@ import (
 	"io/fs"
 	"os"
 	"path"
+
+	"github.com/gravitational/gamma/pkg/schema"
 )
 
 type Workspaces struct {
@@ -71,11 +73,20 @@ type PackageInfo struct {
 	Version    string          `json:"version"`
 	Repository *RepositoryInfo `json:"repository,omitempty"`
 	Workspaces Workspaces      `json:"workspaces"`
+	Gamma      *GammaConfig    `json:"gamma,omitempty"`
 
 	Path     string
 	RootPath string
 }
 
+// GammaConfig is read from the gamma field of package.json
+type GammaConfig struct {
+	Build  *schema.BuildConfig `json:"build,omitempty"`
+	Assets []string            `json:"assets,omitempty"`
+	Files  []string            `json:"files,omitempty"`
+	schema.Release
+}
+
 type RepositoryInfo struct {
 	URL  string  `json:"url"`
 	Type *string `json:"type,omitempty"`
@@ -0,0 +1,204 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gravitational/gamma/pkg/schema"
+)
+
+// DefaultRunner is used when neither the workspace nor the action pick one
+const DefaultRunner = "nx"
+
+// DefaultDist is the directory the built action is collected from
+const DefaultDist = "dist"
+
+type preset struct {
+	args func(name string) []string
+	// root indicates the command runs from the root of the monorepo rather
+	// than from the action
+	root bool
+}
+
+var presets = map[string]preset{
+	"nx": {
+		args: func(name string) []string {
+			return []string{"pnpm", "exec", "nx", "run", fmt.Sprintf("%s:build", name)}
+		},
+	},
+	"pnpm": {
+		args: func(_ string) []string {
+			return []string{"pnpm", "run", "build"}
+		},
+	},
+	"npm": {
+		args: func(_ string) []string {
+			return []string{"npm", "run", "build"}
+		},
+	},
+	"yarn": {
+		args: func(name string) []string {
+			return []string{"yarn", "workspace", name, "run", "build"}
+		},
+		root: true,
+	},
+	"bun": {
+		args: func(_ string) []string {
+			return []string{"bun", "run", "build"}
+		},
+	},
+	"turbo": {
+		args: func(name string) []string {
+			return []string{"turbo", "run", "build", fmt.Sprintf("--filter=%s", name)}
+		},
+		root: true,
+	},
+}
+
+// Runners returns the names of the available runners
+func Runners() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+type Runner struct {
+	args []string
+	dir  string
+	env  []string
+	dist string
+}
+
+// Merge returns the build config of an action on top of the workspace one.
+// Picking a runner for the action drops the command of the workspace.
+func Merge(workspace, action *schema.BuildConfig) *schema.BuildConfig {
+	merged := &schema.BuildConfig{}
+
+	for _, c := range []*schema.BuildConfig{workspace, action} {
+		if c == nil {
+			continue
+		}
+
+		if c.Runner != "" {
+			merged.Runner = c.Runner
+			merged.Command = ""
+		}
+		if c.Command != "" {
+			merged.Command = c.Command
+		}
+		if c.Directory != "" {
+			merged.Directory = c.Directory
+		}
+		if c.Dist != "" {
+			merged.Dist = c.Dist
+		}
+		if len(c.Env) > 0 {
+			if merged.Env == nil {
+				merged.Env = make(map[string]string)
+			}
+			for key, value := range c.Env {
+				merged.Env[key] = value
+			}
+		}
+	}
+
+	return merged
+}
+
+// New resolves the build config of the action called name living in
+// actionPath. root is the root of the monorepo, which directories starting
+// with @/ are relative to.
+func New(config *schema.BuildConfig, name, actionPath, root string) (*Runner, error) {
+	if config == nil {
+		config = &schema.BuildConfig{}
+	}
+
+	r := &Runner{
+		dir:  actionPath,
+		dist: DefaultDist,
+	}
+
+	switch {
+	case config.Command != "":
+		r.args = []string{"sh", "-c", config.Command}
+	default:
+		runner := config.Runner
+		if runner == "" {
+			runner = DefaultRunner
+		}
+
+		p, ok := presets[runner]
+		if !ok {
+			return nil, fmt.Errorf("unknown build runner %s, expected one of %s", runner, strings.Join(Runners(), ", "))
+		}
+
+		r.args = p.args(name)
+		if p.root {
+			r.dir = root
+		}
+	}
+
+	if config.Directory != "" {
+		r.dir = resolve(config.Directory, actionPath, root)
+	}
+
+	if config.Dist != "" {
+		dist := path.Clean(config.Dist)
+		// the action directory itself would be moved into the output
+		if path.IsAbs(dist) || dist == "." || dist == ".." || strings.HasPrefix(dist, "../") {
+			return nil, fmt.Errorf("the dist directory %s must be a subdirectory of the action", config.Dist)
+		}
+
+		r.dist = dist
+	}
+
+	r.env = append(r.env, fmt.Sprintf("GAMMA_ACTION_NAME=%s", name), fmt.Sprintf("GAMMA_ACTION_PATH=%s", actionPath))
+
+	var keys []string
+	for key := range config.Env {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		r.env = append(r.env, fmt.Sprintf("%s=%s", key, config.Env[key]))
+	}
+
+	return r, nil
+}
+
+// Command returns the command building the action
+func (r *Runner) Command() *exec.Cmd {
+	cmd := exec.Command(r.args[0], r.args[1:]...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), r.env...)
+
+	return cmd
+}
+
+// Dist returns the directory of the built action, relative to the action
+func (r *Runner) Dist() string {
+	return r.dist
+}
+
+func resolve(p, actionPath, root string) string {
+	if strings.HasPrefix(p, "@/") {
+		return path.Join(root, strings.TrimPrefix(p, "@/"))
+	}
+
+	if path.IsAbs(p) {
+		return p
+	}
+
+	return path.Join(actionPath, p)
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/gravitational/gamma/pkg/schema"
+)
+
+func TestNewDist(t *testing.T) {
+	tests := []struct {
+		dist    string
+		want    string
+		wantErr bool
+	}{
+		{dist: "", want: DefaultDist},
+		{dist: "build", want: "build"},
+		{dist: "./out/action/", want: "out/action"},
+		{dist: ".", wantErr: true},
+		{dist: "./", wantErr: true},
+		{dist: "..", wantErr: true},
+		{dist: "../dist", wantErr: true},
+		{dist: "build/../..", wantErr: true},
+		{dist: "/tmp/dist", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dist, func(t *testing.T) {
+			r, err := New(&schema.BuildConfig{Dist: tt.dist}, "example", "/repo/actions/example", "/repo")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", r.Dist())
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := r.Dist(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
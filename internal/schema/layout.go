@@ -0,0 +1,215 @@
+package schema
+
+import (
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/gravitational/gamma/pkg/schema"
+)
+
+// RestoreLayout copies the comments of the source files onto a node encoded
+// from the merged config, and orders its top-level keys like the file of the
+// action. Inputs, outputs and steps keep the comments and key order of the
+// file they were defined in.
+func (p *Parser) RestoreLayout(config *schema.Config, node *yaml.Node) {
+	document, ok := p.documents.Get(config.Path)
+	if !ok {
+		return
+	}
+
+	root, source := node, document
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if len(source.Content) > 0 {
+		source = source.Content[0]
+	}
+
+	copyComments(root, source)
+	orderKeys(root, source)
+	orderKeys(lookup(root, "runs"), lookup(source, "runs"))
+
+	// a comment at the top of the file belongs to the document
+	if root != node {
+		copyComments(node, document)
+	} else if document.HeadComment != "" {
+		root.HeadComment = joinComments(document.HeadComment, root.HeadComment)
+	}
+
+	if config.Inputs != nil {
+		forEachEntry(lookup(root, "inputs"), func(key, value *yaml.Node) {
+			if input, ok := config.Inputs.Get(key.Value); ok {
+				p.restoreEntry(input.Position, key, value)
+			}
+		})
+	}
+
+	if config.Outputs != nil {
+		forEachEntry(lookup(root, "outputs"), func(key, value *yaml.Node) {
+			if output, ok := config.Outputs.Get(key.Value); ok {
+				p.restoreEntry(output.Position, key, value)
+			}
+		})
+	}
+
+	if config.Runs.CompositeRun != nil {
+		steps := lookup(lookup(root, "runs"), "steps")
+
+		for i, step := range config.Runs.CompositeRun.Steps {
+			if item := lookup(steps, strconv.Itoa(i)); item != nil {
+				p.restoreEntry(step.Position, nil, item)
+			}
+		}
+	}
+}
+
+// restoreEntry replaces the comments of an entry with the comments of the
+// entry defined at the position, and orders its keys like it
+func (p *Parser) restoreEntry(position schema.Position, key, value *yaml.Node) {
+	clearComments(key)
+	clearComments(value)
+
+	document, ok := p.documents.Get(position.File)
+	if !ok {
+		return
+	}
+
+	sourceKey, sourceValue := findEntry(document, position)
+	if sourceValue == nil {
+		return
+	}
+
+	copyComments(key, sourceKey)
+	copyComments(value, sourceValue)
+	orderKeys(value, sourceValue)
+}
+
+// findEntry returns the mapping entry whose key, or the sequence item, is at
+// the position
+func findEntry(node *yaml.Node, position schema.Position) (*yaml.Node, *yaml.Node) {
+	at := func(n *yaml.Node) bool {
+		return n.Line == position.Line && n.Column == position.Column
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if key, value := findEntry(child, position); value != nil {
+				return key, value
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if at(item) {
+				return nil, item
+			}
+
+			if key, value := findEntry(item, position); value != nil {
+				return key, value
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if at(node.Content[i]) {
+				return node.Content[i], node.Content[i+1]
+			}
+
+			if key, value := findEntry(node.Content[i+1], position); value != nil {
+				return key, value
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// copyComments copies the comments of src onto dst, matching mapping entries
+// by key and sequence items by index
+func copyComments(dst, src *yaml.Node) {
+	if dst == nil || src == nil || dst.Kind != src.Kind {
+		return
+	}
+
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+
+	switch dst.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for i := 0; i < len(dst.Content) && i < len(src.Content); i++ {
+			copyComments(dst.Content[i], src.Content[i])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			for j := 0; j+1 < len(src.Content); j += 2 {
+				if dst.Content[i].Value == src.Content[j].Value {
+					copyComments(dst.Content[i], src.Content[j])
+					copyComments(dst.Content[i+1], src.Content[j+1])
+
+					break
+				}
+			}
+		}
+	}
+}
+
+func joinComments(a, b string) string {
+	if b == "" {
+		return a
+	}
+
+	return a + "\n\n" + b
+}
+
+func clearComments(node *yaml.Node) {
+	if node == nil {
+		return
+	}
+
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+
+	for _, child := range node.Content {
+		clearComments(child)
+	}
+}
+
+// orderKeys sorts the entries of a mapping in the order of the source, the
+// entries the source doesn't have keep their order after them
+func orderKeys(node, source *yaml.Node) {
+	if node == nil || source == nil || node.Kind != yaml.MappingNode || source.Kind != yaml.MappingNode {
+		return
+	}
+
+	var ordered []*yaml.Node
+	used := make(map[int]bool)
+
+	for j := 0; j+1 < len(source.Content); j += 2 {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !used[i] && node.Content[i].Value == source.Content[j].Value {
+				ordered = append(ordered, node.Content[i], node.Content[i+1])
+				used[i] = true
+
+				break
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !used[i] {
+			ordered = append(ordered, node.Content[i], node.Content[i+1])
+		}
+	}
+
+	node.Content = ordered
+}
+
+func forEachEntry(node *yaml.Node, fn func(key, value *yaml.Node)) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fn(node.Content[i], node.Content[i+1])
+	}
+}
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRestoreLayoutKeepsKeyOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	shared := `runs:
+  using: composite
+  steps:
+    - id: shared
+      # comes from the shared file
+      run: echo shared
+      shell: bash
+`
+
+	definition := `name: Example
+description: An example
+inputs:
+  token:
+    required: true
+    description: A token
+extend:
+  - from: shared.yml
+    include:
+      - field: runs
+        mode: prepend
+runs:
+  using: composite
+  steps:
+    - id: own
+      run: echo own
+      shell: bash
+`
+
+	want := `name: Example
+description: An example
+inputs:
+  token:
+    required: true
+    description: A token
+runs:
+  using: composite
+  steps:
+    - id: shared
+      # comes from the shared file
+      run: echo shared
+      shell: bash
+    - id: own
+      run: echo own
+      shell: bash
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "shared.yml"), []byte(shared), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "action.yml"), []byte(definition), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewParser(dir)
+
+	config, err := p.GetConfig(filepath.Join(dir, "action.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
+		t.Fatal(err)
+	}
+
+	p.RestoreLayout(config, &node)
+
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&node); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
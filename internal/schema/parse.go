@@ -2,36 +2,110 @@ package schema
 
 import (
 	"fmt"
-	"os"
-	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 
+	gogit "github.com/go-git/go-git/v5"
 	"gopkg.in/yaml.v3"
 
 	"github.com/gravitational/gamma/internal/cache"
 	"github.com/gravitational/gamma/pkg/schema"
 )
 
-var configCache = cache.New[*schema.Config]()
+// Parser resolves action definitions and their extends. Resolved configs
+// are cached for the lifetime of the parser, which should be scoped to a
+// single command.
+type Parser struct {
+	root  string
+	cache cache.Cache[*schema.Config]
+	// documents are the parsed source files, used to restore their comments
+	documents cache.Cache[*yaml.Node]
+
+	mu           sync.Mutex
+	repositories map[string]*gogit.Repository
+}
+
+func NewParser(root string) *Parser {
+	return &Parser{
+		root:         root,
+		cache:        cache.New[*schema.Config](),
+		documents:    cache.New[*yaml.Node](),
+		repositories: make(map[string]*gogit.Repository),
+	}
+}
+
+// GetConfig reads filename and merges all the files it extends into it
+func (p *Parser) GetConfig(filename string) (*schema.Config, error) {
+	return p.getConfig(source{root: p.root, path: filename}, nil)
+}
+
+// getConfig resolves src, chain is the list of sources currently being
+// resolved that lead to it
+func (p *Parser) getConfig(src source, chain []source) (*schema.Config, error) {
+	filename := src.String()
+
+	for i, s := range chain {
+		if s == src {
+			return nil, fmt.Errorf("extend cycle detected: %s", p.describeChain(append(chain[i:], src)))
+		}
+	}
+
+	if config, ok := p.cache.Get(filename); ok {
+		return config, nil
+	}
 
-func GetConfig(root, filename string) (*schema.Config, error) {
 	var config schema.CustomConfig
 
-	contents, err := os.ReadFile(filename)
+	contents, err := p.read(src)
 	if err != nil {
 		return nil, fmt.Errorf("error reading %s: %v", filename, err)
 	}
 
-	if err := yaml.Unmarshal(contents, &config); err != nil {
+	var document yaml.Node
+	if err := yaml.Unmarshal(contents, &document); err != nil {
 		return nil, fmt.Errorf("error parsing %s: %v", filename, err)
 	}
 
+	if len(document.Content) > 0 {
+		if err := document.Content[0].Decode(&config); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", filename, err)
+		}
+
+		setPositions(&config, document.Content[0], filename)
+	}
+
 	config.Path = filename
 
-	return parseCustomConfig(root, filename, config)
+	p.documents.Set(filename, &document)
+
+	resolved, err := p.parseCustomConfig(src, config, append(chain, src))
+	if err != nil {
+		return nil, err
+	}
+
+	p.cache.Set(filename, resolved)
+
+	return resolved, nil
+}
+
+func (p *Parser) describeChain(chain []source) string {
+	var files []string
+	for _, s := range chain {
+		f := s.String()
+		if s.repository == "" {
+			if rel, err := filepath.Rel(p.root, f); err == nil {
+				f = rel
+			}
+		}
+
+		files = append(files, f)
+	}
+
+	return strings.Join(files, " -> ")
 }
 
-func parseCustomConfig(root, filename string, customConfig schema.CustomConfig) (*schema.Config, error) {
+func (p *Parser) parseCustomConfig(src source, customConfig schema.CustomConfig, chain []source) (*schema.Config, error) {
 	config := &schema.Config{
 		Path:        customConfig.Path,
 		Name:        customConfig.Name,
@@ -41,36 +115,42 @@ func parseCustomConfig(root, filename string, customConfig schema.CustomConfig)
 		Outputs:     customConfig.Outputs,
 		Runs:        customConfig.Runs,
 		Branding:    customConfig.Branding,
+		Extra:       customConfig.Extra,
+	}
+
+	if customConfig.Vars != nil {
+		config.Vars = make(map[string]string)
+		for key, value := range *customConfig.Vars {
+			config.Vars[key] = value
+		}
 	}
 
 	if customConfig.Extend != nil {
 		for _, extension := range *customConfig.Extend {
-			file := extension.From
-			if strings.HasPrefix(file, "@/") {
-				file = strings.TrimPrefix(file, "@/")
-				file = path.Join(root, file)
+			sources, err := p.resolveExtend(src, extension.From)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", extension.Position, err)
 			}
-			if !path.IsAbs(file) {
-				file = path.Join(filename, file)
-			}
-
-			var extensionConfig *schema.Config
-			var ok bool
 
-			extensionConfig, ok = configCache.Get(file)
-			if !ok {
-				def, err := GetConfig(root, file)
+			for _, s := range sources {
+				extensionConfig, err := p.getConfig(s, chain)
 				if err != nil {
-					return nil, err
+					return nil, fmt.Errorf("%s: could not extend from %s: %v", extension.Position, extension.From, err)
 				}
 
-				configCache.Set(file, def)
-
-				extensionConfig = def
-			}
+				if err := mergeConfigs(config, extensionConfig, extension.Include); err != nil {
+					return nil, err
+				}
 
-			if err := mergeConfigs(config, extensionConfig, extension.Include); err != nil {
-				return nil, err
+				// variables of the config take precedence over the extensions,
+				// which only apply when the config has its own vars block
+				if config.Vars != nil {
+					for key, value := range extensionConfig.Vars {
+						if _, ok := config.Vars[key]; !ok {
+							config.Vars[key] = value
+						}
+					}
+				}
 			}
 		}
 	}
@@ -83,27 +163,33 @@ func mergeConfigs(base, extension *schema.Config, includes *[]schema.ExtensionIn
 		for _, include := range *includes {
 			field := include.Field
 
+			if len(include.Rename) > 0 && field != "inputs" {
+				return includeError(&include, "rename is only supported for inputs")
+			}
+
+			var err error
+
 			switch field {
+			case "name":
+				err = mergeString(&base.Name, extension.Name, extension, &include)
+			case "description":
+				err = mergeString(&base.Description, extension.Description, extension, &include)
 			case "inputs":
-				if err := mergeInputs(base, extension, &include); err != nil {
-					return err
-				}
+				err = mergeInputs(base, extension, &include)
 			case "outputs":
-				if err := mergeOutputs(base, extension, &include); err != nil {
-					return err
-				}
+				err = mergeOutputs(base, extension, &include)
 			case "branding":
-				if err := mergeBranding(base, extension, &include); err != nil {
-					return err
-				}
+				err = mergeBranding(base, extension, &include)
 			case "author":
-				if err := mergeAuthor(base, extension); err != nil {
-					return err
-				}
+				err = mergeAuthor(base, extension, &include)
 			case "runs":
-				if err := mergeRuns(base, extension); err != nil {
-					return err
-				}
+				err = mergeRuns(base, extension, &include)
+			default:
+				err = mergeExtra(base, extension, &include)
+			}
+
+			if err != nil {
+				return err
 			}
 		}
 
@@ -113,44 +199,117 @@ func mergeConfigs(base, extension *schema.Config, includes *[]schema.ExtensionIn
 	_ = mergeInputs(base, extension, nil)
 	_ = mergeOutputs(base, extension, nil)
 	_ = mergeBranding(base, extension, nil)
-	_ = mergeAuthor(base, extension)
-	_ = mergeRuns(base, extension)
+	_ = mergeAuthor(base, extension, nil)
+	_ = mergeRuns(base, extension, nil)
 
 	return nil
 }
 
 func mergeInputs(base, extension *schema.Config, includes *schema.ExtensionInclude) error {
 	if extension.Inputs == nil {
-		return fmt.Errorf("no inputs exist in %s", extension.Path)
+		return includeError(includes, "no inputs exist in %s", extension.Path)
+	}
+
+	mode := modeReplace
+	if includes != nil && includes.Mode != "" {
+		mode = includes.Mode
+	}
+
+	if mode != modeReplace && mode != modeOverride {
+		return includeError(includes, "unsupported mode %s for inputs, expected %s or %s", mode, modeReplace, modeOverride)
 	}
 
-	newInputs := make(schema.InputMap)
+	renames := make(map[string]string)
+	if includes != nil {
+		for _, rename := range includes.Rename {
+			if _, ok := extension.Inputs.Get(rename.From); !ok {
+				return includeError(includes, "cannot rename input %s, it does not exist in %s", rename.From, extension.Path)
+			}
+
+			if rename.To == "" {
+				return includeError(includes, "cannot rename input %s to an empty name", rename.From)
+			}
+
+			renames[rename.From] = rename.To
+		}
+
+		targets := make(map[string]string)
+		for _, rename := range includes.Rename {
+			from, to := rename.From, rename.To
+			if other, ok := targets[to]; ok {
+				return includeError(includes, "cannot rename inputs %s and %s both to %s", other, from, to)
+			}
+
+			targets[to] = from
 
+			// an input of the extension keeping the name
+			if _, ok := extension.Inputs.Get(to); ok {
+				if _, renamed := renames[to]; !renamed {
+					return includeError(includes, "cannot rename input %s to %s, it already exists in %s", from, to, extension.Path)
+				}
+			}
+
+			if base.Inputs == nil || mode == modeOverride {
+				continue
+			}
+
+			input, _ := extension.Inputs.Get(from)
+			if existing, ok := base.Inputs.Get(to); ok && existing.Position != input.Position {
+				return includeError(includes, "cannot rename input %s to %s, it is already defined at %s", from, to, existing.Position)
+			}
+		}
+	}
+
+	newInputs := &schema.InputMap{}
 	if base.Inputs != nil {
-		for key, value := range *base.Inputs {
-			newInputs[key] = value
+		newInputs = base.Inputs.Clone()
+	}
+
+	// add sets an input of the extension, where conflicts are either errors
+	// or overridden by the input already defined
+	add := func(key string, input schema.Input, conflicts bool) error {
+		if name, ok := renames[key]; ok {
+			key = name
+		}
+
+		if existing, ok := newInputs.Get(key); ok {
+			// the same definition reached through two extends
+			if existing.Position == input.Position {
+				return nil
+			}
+
+			if mode == modeOverride {
+				newInputs.Set(key, overrideInput(input, existing))
+
+				return nil
+			}
+
+			if conflicts {
+				return includeError(includes, "conflicting input %s, defined at %s and %s", key, existing.Position, input.Position)
+			}
 		}
+
+		newInputs.Set(key, input)
+
+		return nil
 	}
 
 	if includes != nil && includes.Include != nil {
 		for _, field := range *includes.Include {
-
-			inputs := *extension.Inputs
-
-			input, ok := inputs[field]
+			input, ok := extension.Inputs.Get(field)
 			if !ok {
-				return fmt.Errorf("input %s does not exist in %s", field, extension.Path)
+				return includeError(includes, "input %s does not exist in %s", field, extension.Path)
 			}
 
-			if _, ok := newInputs[field]; ok {
-				return fmt.Errorf("conflicting input, %s exists in both %s and %s", field, base.Path, extension.Path)
+			if err := add(field, input, true); err != nil {
+				return err
 			}
-
-			newInputs[field] = input
 		}
 	} else {
 	outer:
-		for key, input := range *extension.Inputs {
+		for _, key := range extension.Inputs.Keys() {
+			input, _ := extension.Inputs.Get(key)
+
 			if includes != nil && includes.Exclude != nil {
 				for _, exclude := range *includes.Exclude {
 					if exclude == key {
@@ -159,47 +318,87 @@ func mergeInputs(base, extension *schema.Config, includes *schema.ExtensionInclu
 				}
 			}
 
-			newInputs[key] = input
+			_ = add(key, input, false)
 		}
 	}
 
-	base.Inputs = &newInputs
+	base.Inputs = newInputs
 
 	return nil
 }
 
+// overrideInput returns the shared input with the fields set by the local
+// input taking precedence
+func overrideInput(shared, local schema.Input) schema.Input {
+	merged := shared
+	merged.Position = local.Position
+
+	if local.Description != "" {
+		merged.Description = local.Description
+	}
+
+	if local.Required != nil {
+		merged.Required = local.Required
+	}
+
+	if local.Default != nil {
+		merged.Default = local.Default
+	}
+
+	if local.DeprecationMessage != nil {
+		merged.DeprecationMessage = local.DeprecationMessage
+	}
+
+	if local.Type != nil {
+		merged.Type = local.Type
+	}
+
+	if len(local.Extra) > 0 {
+		merged.Extra = make(schema.Extra)
+		for key, value := range shared.Extra {
+			merged.Extra[key] = value
+		}
+		for key, value := range local.Extra {
+			merged.Extra[key] = value
+		}
+	}
+
+	return merged
+}
+
 func mergeOutputs(base, extension *schema.Config, includes *schema.ExtensionInclude) error {
 	if extension.Outputs == nil {
-		return fmt.Errorf("no outputs exist in %s", extension.Path)
+		return includeError(includes, "no outputs exist in %s", extension.Path)
 	}
 
-	newOutputs := make(schema.OutputMap)
-
+	newOutputs := &schema.OutputMap{}
 	if base.Outputs != nil {
-		for key, value := range *base.Outputs {
-			newOutputs[key] = value
-		}
+		newOutputs = base.Outputs.Clone()
 	}
 
 	if includes != nil && includes.Include != nil {
 		for _, field := range *includes.Include {
-
-			outputs := *extension.Outputs
-
-			output, ok := outputs[field]
+			output, ok := extension.Outputs.Get(field)
 			if !ok {
-				return fmt.Errorf("output %s does not exist in %s", field, extension.Path)
+				return includeError(includes, "output %s does not exist in %s", field, extension.Path)
 			}
 
-			if _, ok := newOutputs[field]; ok {
-				return fmt.Errorf("conflicting output, %s exists in both %s and %s", field, base.Path, extension.Path)
+			if existing, ok := newOutputs.Get(field); ok {
+				// the same definition reached through two extends
+				if existing.Position == output.Position {
+					continue
+				}
+
+				return includeError(includes, "conflicting output %s, defined at %s and %s", field, existing.Position, output.Position)
 			}
 
-			newOutputs[field] = output
+			newOutputs.Set(field, output)
 		}
 	} else {
 	outer:
-		for key, output := range *extension.Outputs {
+		for _, key := range extension.Outputs.Keys() {
+			output, _ := extension.Outputs.Get(key)
+
 			if includes != nil && includes.Exclude != nil {
 				for _, exclude := range *includes.Exclude {
 					if exclude == key {
@@ -208,18 +407,18 @@ func mergeOutputs(base, extension *schema.Config, includes *schema.ExtensionIncl
 				}
 			}
 
-			newOutputs[key] = output
+			newOutputs.Set(key, output)
 		}
 	}
 
-	base.Outputs = &newOutputs
+	base.Outputs = newOutputs
 
 	return nil
 }
 
 func mergeBranding(base, extension *schema.Config, includes *schema.ExtensionInclude) error {
 	if extension.Branding == nil {
-		return fmt.Errorf("no branding exists in %s", extension.Path)
+		return includeError(includes, "no branding exists in %s", extension.Path)
 	}
 
 	newBranding := &schema.Branding{}
@@ -266,40 +465,276 @@ func mergeBranding(base, extension *schema.Config, includes *schema.ExtensionInc
 	return nil
 }
 
-func mergeRuns(base, extension *schema.Config) error {
+const (
+	modeReplace = "replace"
+	modePrepend = "prepend"
+	modeAppend  = "append"
+	// modeOverride keeps the fields of inputs the action already defines
+	modeOverride = "override"
+)
+
+func mergeRuns(base, extension *schema.Config, include *schema.ExtensionInclude) error {
 	if extension.Runs.JavascriptRun == nil &&
 		extension.Runs.DockerRun == nil &&
 		extension.Runs.CompositeRun == nil {
-		return fmt.Errorf("runs is empty in %s", extension.Path)
+		return includeError(include, "runs is empty in %s", extension.Path)
+	}
+
+	mode := modeReplace
+	if include != nil && include.Mode != "" {
+		mode = include.Mode
+	}
+
+	switch mode {
+	case modeReplace:
+	case modePrepend, modeAppend:
+		if base.Runs.CompositeRun == nil || extension.Runs.CompositeRun == nil {
+			return includeError(include, "runs can only be merged with mode %s when both %s and %s are composite actions", mode, base.Path, extension.Path)
+		}
+	default:
+		return includeError(include, "unsupported mode %s for runs, expected %s, %s or %s", mode, modeReplace, modePrepend, modeAppend)
 	}
 
 	if extension.Runs.JavascriptRun != nil {
 		base.Runs = schema.Runs{
+			Using:         extension.Runs.Using,
 			JavascriptRun: extension.Runs.JavascriptRun,
 		}
 	}
 
 	if extension.Runs.DockerRun != nil {
 		base.Runs = schema.Runs{
+			Using:     extension.Runs.Using,
 			DockerRun: extension.Runs.DockerRun,
 		}
 	}
 
 	if extension.Runs.CompositeRun != nil {
+		steps, err := filterSteps(extension, include)
+		if err != nil {
+			return err
+		}
+
+		var baseSteps []schema.RunStep
+		if mode != modeReplace {
+			baseSteps = base.Runs.CompositeRun.Steps
+		}
+
 		base.Runs = schema.Runs{
-			CompositeRun: extension.Runs.CompositeRun,
+			Using: extension.Runs.Using,
+			CompositeRun: &schema.CompositeRun{
+				Using: extension.Runs.CompositeRun.Using,
+				Steps: mergeSteps(baseSteps, steps, mode),
+				Extra: extension.Runs.CompositeRun.Extra,
+			},
 		}
 	}
 
 	return nil
 }
 
-func mergeAuthor(base, extension *schema.Config) error {
+// filterSteps returns the composite steps of the extension selected by the
+// step ids of the include and exclude lists
+func filterSteps(extension *schema.Config, include *schema.ExtensionInclude) ([]schema.RunStep, error) {
+	steps := extension.Runs.CompositeRun.Steps
+
+	if include == nil || (include.Include == nil && include.Exclude == nil) {
+		return steps, nil
+	}
+
+	byID := make(map[string]schema.RunStep)
+	for _, step := range steps {
+		if step.ID != nil {
+			byID[*step.ID] = step
+		}
+	}
+
+	var filtered []schema.RunStep
+
+	if include.Include != nil {
+		for _, id := range *include.Include {
+			step, ok := byID[id]
+			if !ok {
+				return nil, includeError(include, "step %s does not exist in %s", id, extension.Path)
+			}
+
+			filtered = append(filtered, step)
+		}
+
+		return filtered, nil
+	}
+
+outer:
+	for _, step := range steps {
+		if step.ID != nil {
+			for _, exclude := range *include.Exclude {
+				if exclude == *step.ID {
+					continue outer
+				}
+			}
+		}
+
+		filtered = append(filtered, step)
+	}
+
+	return filtered, nil
+}
+
+// mergeSteps adds the extension steps before or after the base steps. An
+// extension step with the id of a base step replaces it in place instead.
+func mergeSteps(base, extension []schema.RunStep, mode string) []schema.RunStep {
+	merged := append([]schema.RunStep{}, base...)
+
+	positions := make(map[string]int)
+	defined := make(map[schema.Position]struct{})
+	for i, step := range merged {
+		if step.ID != nil {
+			positions[*step.ID] = i
+		}
+		if step.Position.Line != 0 {
+			defined[step.Position] = struct{}{}
+		}
+	}
+
+	var added []schema.RunStep
+
+	for _, step := range extension {
+		// the same step reached through two extends is only added once
+		if _, ok := defined[step.Position]; ok && step.Position.Line != 0 {
+			continue
+		}
+
+		if step.ID != nil {
+			if i, ok := positions[*step.ID]; ok {
+				merged[i] = step
+
+				continue
+			}
+		}
+
+		added = append(added, step)
+	}
+
+	if mode == modePrepend {
+		return append(added, merged...)
+	}
+
+	return append(merged, added...)
+}
+
+func mergeAuthor(base, extension *schema.Config, include *schema.ExtensionInclude) error {
 	if extension.Author == nil {
-		return fmt.Errorf("author is empty in %s", extension.Path)
+		return includeError(include, "author is empty in %s", extension.Path)
+	}
+
+	var author string
+	if base.Author != nil {
+		author = *base.Author
+	}
+
+	if err := mergeString(&author, *extension.Author, extension, include); err != nil {
+		return err
 	}
 
-	base.Author = extension.Author
+	base.Author = &author
 
 	return nil
 }
+
+// mergeString replaces, prepends or appends the value of the extension to a
+// string field of the base, joined with the separator of the include
+func mergeString(base *string, value string, extension *schema.Config, include *schema.ExtensionInclude) error {
+	field := "field"
+	if include != nil {
+		field = include.Field
+	}
+
+	if value == "" {
+		return includeError(include, "%s is empty in %s", field, extension.Path)
+	}
+
+	merged, err := joinString(*base, value, include)
+	if err != nil {
+		return err
+	}
+
+	*base = merged
+
+	return nil
+}
+
+func joinString(base, value string, include *schema.ExtensionInclude) (string, error) {
+	mode := modeReplace
+	separator := "\n"
+
+	if include != nil {
+		if include.Mode != "" {
+			mode = include.Mode
+		}
+
+		if include.Separator != nil {
+			separator = *include.Separator
+		}
+	}
+
+	switch mode {
+	case modeReplace:
+		return value, nil
+	case modePrepend, modeAppend:
+		if base == "" {
+			return value, nil
+		}
+
+		if mode == modePrepend {
+			return value + separator + base, nil
+		}
+
+		return base + separator + value, nil
+	}
+
+	return "", includeError(include, "unsupported mode %s for %s, expected %s, %s or %s", mode, include.Field, modeReplace, modePrepend, modeAppend)
+}
+
+// mergeExtra copies a top-level key gamma doesn't know about from the
+// extension. Strings can be prepended or appended, other values are replaced.
+func mergeExtra(base, extension *schema.Config, include *schema.ExtensionInclude) error {
+	value, ok := extension.Extra[include.Field]
+	if !ok {
+		return includeError(include, "unknown field %s, expected name, description, author, inputs, outputs, runs, branding or a top-level key of %s", include.Field, extension.Path)
+	}
+
+	if base.Extra == nil {
+		base.Extra = make(schema.Extra)
+	}
+
+	if s, ok := value.(string); ok {
+		existing, _ := base.Extra[include.Field].(string)
+
+		merged, err := joinString(existing, s, include)
+		if err != nil {
+			return err
+		}
+
+		base.Extra[include.Field] = merged
+
+		return nil
+	}
+
+	if include.Mode != "" && include.Mode != modeReplace {
+		return includeError(include, "%s can only be merged with mode %s, it is not a string", include.Field, modeReplace)
+	}
+
+	base.Extra[include.Field] = value
+
+	return nil
+}
+
+// includeError prefixes the error with the position of the include it
+// comes from
+func includeError(include *schema.ExtensionInclude, format string, a ...any) error {
+	if include == nil || include.Position.Line == 0 {
+		return fmt.Errorf(format, a...)
+	}
+
+	return fmt.Errorf("%s: %s", include.Position, fmt.Sprintf(format, a...))
+}
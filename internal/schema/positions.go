@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/gravitational/gamma/pkg/schema"
+)
+
+// setPositions records where every input, output, step and extension of the
+// config is defined in its source document
+func setPositions(config *schema.CustomConfig, root *yaml.Node, filename string) {
+	at := func(node *yaml.Node) schema.Position {
+		return schema.Position{File: filename, Line: node.Line, Column: node.Column}
+	}
+
+	if config.Inputs != nil {
+		forEachKey(lookup(root, "inputs"), func(key *yaml.Node) {
+			if input, ok := config.Inputs.Get(key.Value); ok {
+				input.Position = at(key)
+				config.Inputs.Set(key.Value, input)
+			}
+		})
+	}
+
+	if config.Outputs != nil {
+		forEachKey(lookup(root, "outputs"), func(key *yaml.Node) {
+			if output, ok := config.Outputs.Get(key.Value); ok {
+				output.Position = at(key)
+				config.Outputs.Set(key.Value, output)
+			}
+		})
+	}
+
+	if config.Runs.CompositeRun != nil {
+		if steps := lookup(lookup(root, "runs"), "steps"); steps != nil {
+			for i := range config.Runs.CompositeRun.Steps {
+				if node := lookup(steps, strconv.Itoa(i)); node != nil {
+					config.Runs.CompositeRun.Steps[i].Position = at(node)
+				}
+			}
+		}
+	}
+
+	if config.Extend != nil {
+		extends := lookup(root, "extend")
+
+		for i := range *config.Extend {
+			extension := &(*config.Extend)[i]
+
+			node := lookup(extends, strconv.Itoa(i))
+			if node == nil {
+				continue
+			}
+
+			extension.Position = at(node)
+
+			if extension.Include == nil {
+				continue
+			}
+
+			includes := lookup(node, "include")
+
+			for j := range *extension.Include {
+				if include := lookup(includes, strconv.Itoa(j)); include != nil {
+					(*extension.Include)[j].Position = at(include)
+				}
+			}
+		}
+	}
+}
+
+func forEachKey(node *yaml.Node, fn func(key *yaml.Node)) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fn(node.Content[i])
+	}
+}
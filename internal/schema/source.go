@@ -0,0 +1,306 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const gitFilePrefix = "git+file://"
+
+// source is a file an action definition is read from, either in the working
+// tree or at a ref of a git repository
+type source struct {
+	// repository is the directory of the git repository the file is read
+	// from, empty for the working tree
+	repository string
+	ref        string
+	// root is the directory @/ refers to and path the location of the file.
+	// Both are absolute in the working tree and relative to the repository
+	// otherwise.
+	root string
+	path string
+}
+
+func (s source) String() string {
+	if s.repository == "" {
+		return s.path
+	}
+
+	return fmt.Sprintf("%s%s//%s@%s", gitFilePrefix, s.repository, s.path, s.ref)
+}
+
+// resolveExtend returns the sources an extend of the current source refers
+// to. Paths starting with @/ are relative to the root, other relative paths
+// to the directory of the current source. A trailing @ref reads the file at
+// that ref of the monorepo, and git+file:///repo//file@ref reads it from
+// another repository. Globs extend every matching file in lexical order.
+func (p *Parser) resolveExtend(current source, from string) ([]source, error) {
+	if from == "~" || strings.HasPrefix(from, "~/") {
+		return nil, fmt.Errorf("cannot extend from %s, home directories are not supported, use @/ for the root of the monorepo", from)
+	}
+
+	target := current
+	file := from
+
+	if strings.HasPrefix(file, gitFilePrefix) {
+		parts := strings.SplitN(strings.TrimPrefix(file, gitFilePrefix), "//", 2)
+		if len(parts) != 2 || !path.IsAbs(parts[0]) {
+			return nil, fmt.Errorf("cannot extend from %s, expected %s/path/to/repo//path/to/file.yml@ref", from, gitFilePrefix)
+		}
+
+		target = source{repository: path.Clean(parts[0]), ref: "HEAD"}
+		file = "@/" + parts[1]
+	} else if strings.Contains(file, "://") {
+		return nil, fmt.Errorf("cannot extend from %s, only %s URLs are supported", from, gitFilePrefix)
+	}
+
+	if i := refIndex(file); i > 0 {
+		ref := file[i+1:]
+		file = file[:i]
+
+		if ref == "" {
+			return nil, fmt.Errorf("cannot extend from %s, the ref is empty", from)
+		}
+
+		if target.repository == "" {
+			t, err := p.monorepoSource(current)
+			if err != nil {
+				return nil, err
+			}
+
+			target = t
+		}
+
+		target.ref = ref
+	}
+
+	switch {
+	case strings.HasPrefix(file, "@/"):
+		target.path = path.Join(target.root, strings.TrimPrefix(file, "@/"))
+
+		if !isWithin(target.root, target.path) {
+			return nil, fmt.Errorf("cannot extend from %s, it is outside of the root of the monorepo", from)
+		}
+	case path.IsAbs(file):
+		if target.repository != "" {
+			return nil, fmt.Errorf("cannot extend from %s, absolute paths can't be read from a git ref", from)
+		}
+
+		target.path = file
+	default:
+		target.path = path.Join(path.Dir(target.path), file)
+	}
+
+	if target.repository != "" && !isWithin("", target.path) {
+		return nil, fmt.Errorf("cannot extend from %s, it is outside of the repository", from)
+	}
+
+	if !strings.ContainsAny(target.path, "*?[") {
+		return []source{target}, nil
+	}
+
+	matches, err := p.glob(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %s: %v", from, err)
+	}
+
+	var sources []source
+	for _, match := range matches {
+		s := target
+		s.path = match
+
+		// a glob matching the file itself isn't a cycle
+		if s != current {
+			sources = append(sources, s)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("cannot extend from %s, it does not match any file", from)
+	}
+
+	return sources, nil
+}
+
+// refIndex returns the index of the @ separating a ref from the file, or -1.
+// The ref follows the .yml or .yaml extension, so that it can contain slashes,
+// other files only take a ref in their last path element. @/ is never a ref.
+func refIndex(file string) int {
+	index := -1
+
+	for _, ext := range []string{".yml@", ".yaml@"} {
+		if i := strings.Index(file, ext); i >= 0 && (index < 0 || i+len(ext)-1 < index) {
+			index = i + len(ext) - 1
+		}
+	}
+
+	if index < 0 {
+		if i := strings.LastIndex(file, "@"); i > strings.LastIndex(file, "/") {
+			index = i
+		}
+	}
+
+	return index
+}
+
+// monorepoSource converts a source of the working tree into a source of the
+// git repository containing it, at HEAD
+func (p *Parser) monorepoSource(current source) (source, error) {
+	repo, err := p.openRepository(p.root)
+	if err != nil {
+		return source{}, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return source{}, fmt.Errorf("could not get the worktree of %s: %v", p.root, err)
+	}
+
+	top := wt.Filesystem.Root()
+
+	root, err := filepath.Rel(top, current.root)
+	if err != nil {
+		return source{}, err
+	}
+
+	file, err := filepath.Rel(top, current.path)
+	if err != nil {
+		return source{}, err
+	}
+
+	return source{
+		repository: top,
+		ref:        "HEAD",
+		root:       filepath.ToSlash(root),
+		path:       filepath.ToSlash(file),
+	}, nil
+}
+
+func (p *Parser) openRepository(dir string) (*gogit.Repository, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if repo, ok := p.repositories[dir]; ok {
+		return repo, nil
+	}
+
+	repo, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("could not open the git repository %s: %v", dir, err)
+	}
+
+	p.repositories[dir] = repo
+
+	return repo, nil
+}
+
+func (p *Parser) tree(s source) (*object.Tree, error) {
+	repo, err := p.openRepository(s.repository)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(s.ref))
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %s in %s: %v", s.ref, s.repository, err)
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("could not get commit %s in %s: %v", s.ref, s.repository, err)
+	}
+
+	return commit.Tree()
+}
+
+// read returns the contents of the source
+func (p *Parser) read(s source) ([]byte, error) {
+	if s.repository == "" {
+		return os.ReadFile(s.path)
+	}
+
+	tree, err := p.tree(s)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := tree.File(s.path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, fmt.Errorf("%s does not exist at %s", s.path, s.ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(contents), nil
+}
+
+// glob returns the sorted paths matching the path of the source
+func (p *Parser) glob(s source) ([]string, error) {
+	if s.repository == "" {
+		matches, err := filepath.Glob(s.path)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(matches)
+
+		return matches, nil
+	}
+
+	if _, err := path.Match(s.path, ""); err != nil {
+		return nil, err
+	}
+
+	tree, err := p.tree(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if ok, _ := path.Match(s.path, f.Name); ok {
+			matches = append(matches, f.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+
+	return matches, nil
+}
+
+// isWithin reports whether the cleaned path p is inside dir. An empty dir
+// stands for the root of a repository.
+func isWithin(dir, p string) bool {
+	rel := p
+	if dir != "" {
+		r, err := filepath.Rel(dir, p)
+		if err != nil {
+			return false
+		}
+
+		rel = filepath.ToSlash(r)
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, "../") && !path.IsAbs(rel)
+}
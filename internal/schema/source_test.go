@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestRefIndex(t *testing.T) {
+	tests := []struct {
+		file string
+		want int
+	}{
+		{file: "@/shared/common.yml", want: -1},
+		{file: "@/shared/common.yml@v2", want: 19},
+		{file: "@/shared/common.yml@release/v2", want: 19},
+		{file: "@/shared/common.yaml@release/v2.1", want: 20},
+		{file: "@/shared/*.yml@release/v2", want: 14},
+		{file: "@/node_modules/@scope/common.yml@v2", want: 32},
+		{file: "@/shared/common@v2", want: 15},
+		{file: "@/shared/common", want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := refIndex(tt.file); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExtendFromSlashedRef(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := `inputs:
+  token:
+    description: A token
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "shared", "common.yml"), []byte(shared), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wt.Add("shared/common.yml"); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := wt.Commit("add shared inputs", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/release/v2", hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the working tree no longer has the file, only the ref does
+	if err := os.RemoveAll(filepath.Join(dir, "shared")); err != nil {
+		t.Fatal(err)
+	}
+
+	definition := `name: Example
+description: An example
+extend:
+  - from: '@/shared/common.yml@release/v2'
+runs:
+  using: node16
+  main: dist/index.js
+`
+
+	if err := os.WriteFile(filepath.Join(dir, "action.yml"), []byte(definition), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewParser(dir).GetConfig(filepath.Join(dir, "action.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Inputs == nil {
+		t.Fatal("expected the inputs of the shared file")
+	}
+
+	if _, ok := config.Inputs.Get("token"); !ok {
+		t.Error("expected the token input of the shared file")
+	}
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateData is what the templates in an action definition can refer to,
+// e.g. {{ .Action.Name }} or {{ .Vars.example }}
+type TemplateData struct {
+	Action ActionData
+	Repo   RepoData
+	Vars   map[string]string
+}
+
+type ActionData struct {
+	Name    string
+	Version string
+	// Major, Minor and Patch are the parts of a semantic version
+	Major string
+	Minor string
+	Patch string
+}
+
+type RepoData struct {
+	Owner string
+	Name  string
+}
+
+// Interpolate evaluates the templates in every scalar value of the node.
+// Github expressions like ${{ inputs.name }} are left untouched.
+func Interpolate(node *yaml.Node, data TemplateData) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := Interpolate(child, data); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := Interpolate(node.Content[i+1], data); err != nil {
+				return fmt.Errorf("%s: %v", node.Content[i].Value, err)
+			}
+		}
+	case yaml.ScalarNode:
+		value, err := interpolateString(node.Value, data)
+		if err != nil {
+			return err
+		}
+
+		if value != node.Value {
+			// quotes were only needed for the template braces
+			node.Style &^= yaml.SingleQuotedStyle | yaml.DoubleQuotedStyle
+			node.Value = value
+		}
+	}
+
+	return nil
+}
+
+// Render evaluates the templates of a single value
+func Render(value string, data TemplateData) (string, error) {
+	return interpolateString(value, data)
+}
+
+func interpolateString(value string, data TemplateData) (string, error) {
+	masked, expressions := maskExpressions(value)
+	if !strings.Contains(masked, "{{") {
+		return value, nil
+	}
+
+	t, err := template.New("").Option("missingkey=error").Parse(masked)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %v", value, err)
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("could not evaluate template %q: %v", value, err)
+	}
+
+	result := sb.String()
+	for i, expression := range expressions {
+		result = strings.Replace(result, placeholder(i), expression, 1)
+	}
+
+	return result, nil
+}
+
+// maskExpressions replaces the Github expressions in the value with
+// placeholders, so that they aren't mistaken for templates
+func maskExpressions(value string) (string, []string) {
+	var sb strings.Builder
+	var expressions []string
+
+	for {
+		start := strings.Index(value, "${{")
+		if start == -1 {
+			break
+		}
+
+		end := strings.Index(value[start:], "}}")
+		if end == -1 {
+			break
+		}
+
+		end += start + len("}}")
+
+		sb.WriteString(value[:start])
+		sb.WriteString(placeholder(len(expressions)))
+		expressions = append(expressions, value[start:end])
+
+		value = value[end:]
+	}
+
+	sb.WriteString(value)
+
+	return sb.String(), expressions
+}
+
+func placeholder(i int) string {
+	return fmt.Sprintf("\x00expression%d\x00", i)
+}
@@ -0,0 +1,276 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/gravitational/gamma/pkg/schema"
+)
+
+// Problem is an issue found while validating an action definition
+type Problem struct {
+	Position schema.Position
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Position, p.Message)
+}
+
+// brandingColors are the colors supported by the Github Marketplace
+var brandingColors = []string{
+	"white", "black", "yellow", "blue", "green", "orange", "red", "purple", "gray-dark",
+}
+
+// brandingIcons are the Feather icons supported by the Github Marketplace
+var brandingIcons = []string{
+	"activity", "airplay", "alert-circle", "alert-octagon", "alert-triangle", "align-center",
+	"align-justify", "align-left", "align-right", "anchor", "aperture", "archive",
+	"arrow-down-circle", "arrow-down-left", "arrow-down-right", "arrow-down", "arrow-left-circle",
+	"arrow-left", "arrow-right-circle", "arrow-right", "arrow-up-circle", "arrow-up-left",
+	"arrow-up-right", "arrow-up", "at-sign", "award", "bar-chart-2", "bar-chart", "battery-charging",
+	"battery", "bell-off", "bell", "bluetooth", "bold", "book-open", "book", "bookmark", "box",
+	"briefcase", "calendar", "camera-off", "camera", "cast", "check-circle", "check-square", "check",
+	"chevron-down", "chevron-left", "chevron-right", "chevron-up", "chevrons-down", "chevrons-left",
+	"chevrons-right", "chevrons-up", "circle", "clipboard", "clock", "cloud-drizzle",
+	"cloud-lightning", "cloud-off", "cloud-rain", "cloud-snow", "cloud", "code", "command", "compass",
+	"copy", "corner-down-left", "corner-down-right", "corner-left-down", "corner-left-up",
+	"corner-right-down", "corner-right-up", "corner-up-left", "corner-up-right", "cpu",
+	"credit-card", "crop", "crosshair", "database", "delete", "disc", "dollar-sign",
+	"download-cloud", "download", "droplet", "edit-2", "edit-3", "edit", "external-link", "eye-off",
+	"eye", "fast-forward", "feather", "file-minus", "file-plus", "file-text", "file", "film",
+	"filter", "flag", "folder-minus", "folder-plus", "folder", "gift", "git-branch", "git-commit",
+	"git-merge", "git-pull-request", "globe", "grid", "hard-drive", "hash", "headphones", "heart",
+	"help-circle", "home", "image", "inbox", "info", "italic", "layers", "layout", "life-buoy",
+	"link-2", "link", "list", "loader", "lock", "log-in", "log-out", "mail", "map-pin", "map",
+	"maximize-2", "maximize", "menu", "message-circle", "message-square", "mic-off", "mic",
+	"minimize-2", "minimize", "minus-circle", "minus-square", "minus", "monitor", "moon",
+	"more-horizontal", "more-vertical", "move", "music", "navigation-2", "navigation", "octagon",
+	"package", "paperclip", "pause-circle", "pause", "pen-tool", "percent", "phone-call",
+	"phone-forwarded", "phone-incoming", "phone-missed", "phone-off", "phone-outgoing", "phone",
+	"pie-chart", "play-circle", "play", "plus-circle", "plus-square", "plus", "pocket", "power",
+	"printer", "radio", "refresh-ccw", "refresh-cw", "repeat", "rewind", "rotate-ccw", "rotate-cw",
+	"rss", "save", "scissors", "search", "send", "server", "settings", "share-2", "share",
+	"shield-off", "shield", "shopping-bag", "shopping-cart", "shuffle", "sidebar", "skip-back",
+	"skip-forward", "slash", "sliders", "smartphone", "speaker", "square", "star", "stop-circle",
+	"sun", "sunrise", "sunset", "table", "tablet", "tag", "target", "terminal", "thermometer",
+	"thumbs-down", "thumbs-up", "toggle-left", "toggle-right", "trash-2", "trash", "trending-down",
+	"trending-up", "triangle", "truck", "tv", "type", "umbrella", "underline", "unlock",
+	"upload-cloud", "upload", "user-check", "user-minus", "user-plus", "user-x", "user", "users",
+	"video-off", "video", "voicemail", "volume-1", "volume-2", "volume-x", "volume", "watch",
+	"wifi-off", "wifi", "wind", "x-circle", "x-square", "x", "zap-off", "zap", "zoom-in", "zoom-out",
+}
+
+// Validate checks a merged action definition against the Github Actions
+// metadata syntax. Inputs, outputs and steps are reported where they are
+// defined, other positions are looked up in the file the definition was
+// read from.
+func Validate(config *schema.Config) ([]Problem, error) {
+	source, err := readNode(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validator{file: config.Path, source: source}
+
+	v.validateTopLevelKeys(config)
+
+	if config.Name == "" {
+		v.report("name is required", "name")
+	}
+
+	if config.Description == "" {
+		v.report("description is required", "description")
+	}
+
+	if config.Inputs != nil {
+		for _, name := range config.Inputs.Keys() {
+			if input, _ := config.Inputs.Get(name); input.Description == "" {
+				v.reportAt(input.Position, fmt.Sprintf("input %s has no description", name), "inputs", name)
+			}
+		}
+	}
+
+	if config.Branding != nil {
+		if c := config.Branding.Color; c != nil && !contains(brandingColors, *c) {
+			v.report(fmt.Sprintf("branding.color %s is not supported by the Marketplace", *c), "branding", "color")
+		}
+
+		if i := config.Branding.Icon; i != nil && !contains(brandingIcons, *i) {
+			v.report(fmt.Sprintf("branding.icon %s is not supported by the Marketplace", *i), "branding", "icon")
+		}
+	}
+
+	switch {
+	case config.Runs.CompositeRun != nil:
+		v.validateComposite(config)
+	case config.Runs.JavascriptRun == nil && config.Runs.DockerRun == nil:
+		v.report("runs is required", "runs")
+	}
+
+	return v.problems, nil
+}
+
+// NewProblem creates a problem positioned at the keys in the definition
+func NewProblem(config *schema.Config, message string, keys ...string) Problem {
+	source, err := readNode(config.Path)
+	if err != nil {
+		return Problem{Position: schema.Position{File: config.Path}, Message: message}
+	}
+
+	v := &validator{file: config.Path, source: source}
+	v.report(message, keys...)
+
+	return v.problems[0]
+}
+
+type validator struct {
+	file     string
+	source   *yaml.Node
+	problems []Problem
+}
+
+// validateTopLevelKeys reports the keys that are passed through without
+// being part of the metadata syntax
+func (v *validator) validateTopLevelKeys(config *schema.Config) {
+	for _, key := range sortedKeys(config.Extra) {
+		v.report(fmt.Sprintf("unknown key %s", key), key)
+	}
+}
+
+func (v *validator) validateComposite(config *schema.Config) {
+	if config.Outputs != nil {
+		for _, name := range config.Outputs.Keys() {
+			if output, _ := config.Outputs.Get(name); output.Value == "" {
+				v.reportAt(output.Position, fmt.Sprintf("output %s of a composite action has no value", name), "outputs", name)
+			}
+		}
+	}
+
+	ids := make(map[string]schema.Position)
+
+	for i, step := range config.Runs.CompositeRun.Steps {
+		index := strconv.Itoa(i)
+
+		switch {
+		case step.Run != nil && step.Uses != nil:
+			v.reportAt(step.Position, fmt.Sprintf("step %d has both run and uses", i), "runs", "steps", index)
+		case step.Run == nil && step.Uses == nil:
+			v.reportAt(step.Position, fmt.Sprintf("step %d has neither run nor uses", i), "runs", "steps", index)
+		case step.Run != nil && step.Shell == nil:
+			v.reportAt(step.Position, fmt.Sprintf("step %d uses run without a shell", i), "runs", "steps", index)
+		}
+
+		if step.ID != nil {
+			if previous, ok := ids[*step.ID]; ok {
+				v.reportAt(step.Position, fmt.Sprintf("step id %s is not unique, it is also used at %s", *step.ID, previous), "runs", "steps", index)
+			}
+
+			ids[*step.ID] = step.Position
+		}
+	}
+}
+
+// report adds a problem at the deepest node of the source matching the keys
+func (v *validator) report(message string, keys ...string) {
+	position := schema.Position{File: v.file}
+
+	if node := v.source; node != nil {
+		position.Line, position.Column = node.Line, node.Column
+
+		for _, key := range keys {
+			if node = lookup(node, key); node == nil {
+				break
+			}
+
+			position.Line, position.Column = node.Line, node.Column
+		}
+	}
+
+	v.problems = append(v.problems, Problem{
+		Position: position,
+		Message:  message,
+	})
+}
+
+// reportAt adds a problem at a known position, falling back to the keys in
+// the source when the position is unknown
+func (v *validator) reportAt(position schema.Position, message string, keys ...string) {
+	if position.Line == 0 {
+		v.report(message, keys...)
+
+		return
+	}
+
+	v.problems = append(v.problems, Problem{
+		Position: position,
+		Message:  message,
+	})
+}
+
+// lookup returns the value of a key in a mapping, or the item at an index of
+// a sequence
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(key)
+		if err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index]
+		}
+	}
+
+	return nil
+}
+
+func readNode(filename string) (*yaml.Node, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", filename, err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", filename, err)
+	}
+
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	return document.Content[0], nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/gravitational/gamma/internal/logger"
 )
@@ -39,3 +43,115 @@ func FetchWorkingDirectory(dir string) string {
 
 	return wd
 }
+
+// CopyFile copies src to dst, keeping the permission bits of src. Symlinks
+// are recreated rather than followed.
+func CopyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	// replace rather than write through an existing file or symlink
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return fmt.Errorf("could not read link: %v", err)
+		}
+
+		return os.Symlink(target, dst)
+	}
+
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	destination, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("could not create file: %v", err)
+	}
+
+	defer destination.Close()
+
+	if _, err := io.Copy(destination, source); err != nil {
+		return err
+	}
+
+	// the umask may have dropped some of the bits
+	return destination.Chmod(info.Mode().Perm())
+}
+
+// CopyPath copies a file or a whole directory from src to dst
+func CopyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+
+		return CopyFile(src, dst)
+	}
+
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		return CopyFile(p, target)
+	})
+}
+
+// MatchGlob reports whether the slash separated name matches the pattern.
+// On top of the syntax of path.Match, a ** segment matches any number of
+// directories.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gravitational/gamma/internal/action"
 	"github.com/gravitational/gamma/internal/logger"
 	"github.com/gravitational/gamma/internal/node"
+	internalschema "github.com/gravitational/gamma/internal/schema"
 	"github.com/gravitational/gamma/pkg/schema"
 	"gopkg.in/yaml.v3"
 )
@@ -20,13 +21,17 @@ type workspace struct {
 	workingDirectory  string
 	outputDirectory   string
 	workspaceManifest string
+	assets            []string
 	packages          node.PackageService
+	parser            *internalschema.Parser
 }
 
 type Properties struct {
 	WorkingDirectory  string
 	OutputDirectory   string
 	WorkspaceManifest string
+	// Assets are copied into the output of every action
+	Assets []string
 }
 
 func New(props Properties) Workspace {
@@ -41,7 +46,9 @@ func New(props Properties) Workspace {
 		props.WorkingDirectory,
 		props.OutputDirectory,
 		props.WorkspaceManifest,
+		props.Assets,
 		node.NewPackageService(props.WorkingDirectory),
+		internalschema.NewParser(props.WorkingDirectory),
 	}
 }
 
@@ -57,6 +64,13 @@ func (w *workspace) CollectActions(verbose bool) ([]action.Action, error) {
 		return nil, err
 	}
 
+	var buildConfig *schema.BuildConfig
+	var release schema.Release
+	if rootPackage.Gamma != nil {
+		buildConfig = rootPackage.Gamma.Build
+		release = rootPackage.Gamma.Release
+	}
+
 	var actions []action.Action
 	for _, ws := range nodeWorkspaces {
 		outputDirectory := path.Join(w.outputDirectory, ws.Name)
@@ -66,6 +80,10 @@ func (w *workspace) CollectActions(verbose bool) ([]action.Action, error) {
 			WorkingDirectory: w.workingDirectory,
 			OutputDirectory:  outputDirectory,
 			PackageInfo:      ws,
+			Build:            buildConfig,
+			Release:          release,
+			Assets:           w.assets,
+			Parser:           w.parser,
 		}
 
 		action, err := action.New(config)
@@ -96,6 +114,10 @@ func (w *workspace) CollectActions(verbose bool) ([]action.Action, error) {
 				WorkingDirectory: w.workingDirectory,
 				OutputDirectory:  outputDirectory,
 				ActionInfo:       &a,
+				Build:            buildConfig,
+				Release:          release,
+				Assets:           w.assets,
+				Parser:           w.parser,
 			}
 
 			action, err := action.New(config)
@@ -11,49 +11,145 @@ type WorkspaceManifest struct {
 }
 
 type ActionInfo struct {
-	Name            string `yaml:"name"`
-	Version         string `yaml:"version"`
-	OutputDirectory string `yaml:"path"`
-	RepositoryURL   string `yaml:"repository"`
+	Name            string       `yaml:"name"`
+	Version         string       `yaml:"version"`
+	OutputDirectory string       `yaml:"path"`
+	RepositoryURL   string       `yaml:"repository"`
+	Build           *BuildConfig `yaml:"build,omitempty"`
+	Assets          []string     `yaml:"assets,omitempty"`
+	// Files are globs of the files published with the action, relative to
+	// the action. Globs starting with ! exclude files.
+	Files   []string `yaml:"files,omitempty"`
+	Release `yaml:",inline"`
 }
 
+// Release configures where an action is published. It can be set for the
+// whole workspace and overridden per action.
+type Release struct {
+	// Branch of the target repo, a template. Defaults to the branch checked
+	// out in the monorepo.
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	// Tag is the template of the version tags, v{{ .Action.Version }} by default
+	Tag string `yaml:"tag,omitempty" json:"tag,omitempty"`
+}
+
+// BuildConfig configures how an action is built. It can be set for the whole
+// workspace and overridden per action.
+type BuildConfig struct {
+	// Runner is the name of a preset build command: nx, pnpm, npm, yarn, bun or turbo
+	Runner string `yaml:"runner,omitempty" json:"runner,omitempty"`
+	// Command is a shell command used instead of the runner
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+	// Directory the command runs in, relative to the action or to the root with @/
+	Directory string            `yaml:"directory,omitempty" json:"directory,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	// Dist is the directory of the built action, relative to the action
+	Dist string `yaml:"dist,omitempty" json:"dist,omitempty"`
+}
+
+// Extra holds the keys a struct doesn't know about, so that they are passed
+// through rather than lost
+type Extra = map[string]interface{}
+
 type Config struct {
 	Path        string     `yaml:"-"`
 	Name        string     `yaml:"name"`
 	Author      *string    `yaml:"author,omitempty"`
 	Description string     `yaml:"description"`
 	Inputs      *InputMap  `yaml:"inputs,omitempty"`
-	Outputs     *OutputMap `yaml:"output,omitempty"`
+	Outputs     *OutputMap `yaml:"outputs,omitempty"`
 	Runs        Runs       `yaml:"runs"`
 	Branding    *Branding  `yaml:"branding,omitempty"`
+	Extra       Extra      `yaml:",inline"`
+	// Vars are the template variables of the config and its extensions, nil
+	// when the config itself has no vars block, which leaves templates disabled
+	Vars map[string]string `yaml:"-"`
 }
 
 type CustomConfig struct {
-	Path        string       `yaml:"-"`
-	Name        string       `yaml:"name"`
-	Author      *string      `yaml:"author,omitempty"`
-	Description string       `yaml:"description"`
-	Inputs      *InputMap    `yaml:"inputs,omitempty"`
-	Outputs     *OutputMap   `yaml:"output,omitempty"`
-	Runs        Runs         `yaml:"runs"`
-	Branding    *Branding    `yaml:"branding,omitempty"`
-	Extend      *[]Extension `yaml:"extend,omitempty"`
+	Path        string             `yaml:"-"`
+	Name        string             `yaml:"name"`
+	Author      *string            `yaml:"author,omitempty"`
+	Description string             `yaml:"description"`
+	Inputs      *InputMap          `yaml:"inputs,omitempty"`
+	Outputs     *OutputMap         `yaml:"outputs,omitempty"`
+	Runs        Runs               `yaml:"runs"`
+	Branding    *Branding          `yaml:"branding,omitempty"`
+	Extend      *[]Extension       `yaml:"extend,omitempty"`
+	Vars        *map[string]string `yaml:"vars,omitempty"`
+	Extra       Extra              `yaml:",inline"`
+}
+
+// Position is where a value was defined in a YAML file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
 }
 
 type ExtensionInclude struct {
 	Field   string    `yaml:"field"`
 	Include *[]string `yaml:"include"`
 	Exclude *[]string `yaml:"exclude"`
+	// Mode is how the field is merged: replace, prepend or append
+	Mode string `yaml:"mode,omitempty"`
+	// Separator joins prepended or appended strings, a newline by default
+	Separator *string `yaml:"separator,omitempty"`
+	// Rename changes the names of the included inputs
+	Rename Renames `yaml:"rename,omitempty"`
+
+	Position Position `yaml:"-"`
+}
+
+type Rename struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// Renames is either a single rename or a list of them
+type Renames []Rename
+
+func (r *Renames) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var rename Rename
+		if err := value.Decode(&rename); err != nil {
+			return err
+		}
+
+		*r = Renames{rename}
+
+		return nil
+	}
+
+	var renames []Rename
+	if err := value.Decode(&renames); err != nil {
+		return err
+	}
+
+	*r = renames
+
+	return nil
 }
 
 type Extension struct {
 	From    string              `yaml:"from"`
 	Include *[]ExtensionInclude `yaml:"include"`
+
+	Position Position `yaml:"-"`
 }
 
 type Branding struct {
 	Color *string `yaml:"color"`
 	Icon  *string `yaml:"icon"`
+	Extra Extra   `yaml:",inline"`
 }
 
 type Input struct {
@@ -61,16 +157,105 @@ type Input struct {
 	Required           *bool   `yaml:"required,omitempty"`
 	Default            *string `yaml:"default,omitempty"`
 	DeprecationMessage *string `yaml:"deprecationMessage,omitempty"`
+	// Type is a hint for tooling, Github treats every input as a string
+	Type  *string `yaml:"type,omitempty"`
+	Extra Extra   `yaml:",inline"`
+
+	Position Position `yaml:"-"`
 }
 
-type InputMap = map[string]Input
+type InputMap = OrderedMap[Input]
 
 type Output struct {
 	Description string `yaml:"description"`
-	Value       string `yaml:"value"`
+	// Value is only used by composite actions
+	Value string `yaml:"value,omitempty"`
+	Extra Extra  `yaml:",inline"`
+
+	Position Position `yaml:"-"`
 }
 
-type OutputMap = map[string]Output
+type OutputMap = OrderedMap[Output]
+
+// OrderedMap is a YAML mapping that keeps its keys in the order they were
+// added, so that generated files follow the order they were written in
+type OrderedMap[T any] struct {
+	keys   []string
+	values map[string]T
+}
+
+// Keys returns the keys of the map in order
+func (m *OrderedMap[T]) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+func (m *OrderedMap[T]) Len() int {
+	return len(m.keys)
+}
+
+func (m *OrderedMap[T]) Get(key string) (T, bool) {
+	value, ok := m.values[key]
+
+	return value, ok
+}
+
+// Set adds the key at the end of the map, or replaces its value in place
+// when it already exists
+func (m *OrderedMap[T]) Set(key string, value T) {
+	if m.values == nil {
+		m.values = make(map[string]T)
+	}
+
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+
+	m.values[key] = value
+}
+
+// Clone returns a copy of the map that can be modified independently
+func (m *OrderedMap[T]) Clone() *OrderedMap[T] {
+	clone := &OrderedMap[T]{}
+	for _, key := range m.keys {
+		clone.Set(key, m.values[key])
+	}
+
+	return clone
+}
+
+func (m OrderedMap[T]) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+	for _, key := range m.keys {
+		var value yaml.Node
+		if err := value.Encode(m.values[key]); err != nil {
+			return nil, err
+		}
+
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &value)
+	}
+
+	return node, nil
+}
+
+func (m *OrderedMap[T]) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", value.Line)
+	}
+
+	*m = OrderedMap[T]{}
+
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		var item T
+		if err := value.Content[i+1].Decode(&item); err != nil {
+			return err
+		}
+
+		m.Set(value.Content[i].Value, item)
+	}
+
+	return nil
+}
 
 type EnvMap = map[string]string
 type WithMap = map[string]string
@@ -82,6 +267,7 @@ type JavascriptRun struct {
 	PreIf  *string `yaml:"pre-if,omitempty"`
 	Post   *string `yaml:"post,omitempty"`
 	PostIf *string `yaml:"post-if,omitempty"`
+	Extra  Extra   `yaml:",inline"`
 }
 
 type RunStep struct {
@@ -94,20 +280,32 @@ type RunStep struct {
 	WorkingDirectory *string  `yaml:"working-directory,omitempty"`
 	Uses             *string  `yaml:"uses,omitempty"`
 	With             *WithMap `yaml:"with,omitempty"`
+	// ContinueOnError is either a boolean or an expression
+	ContinueOnError interface{} `yaml:"continue-on-error,omitempty"`
+	// TimeoutMinutes is either a number or an expression
+	TimeoutMinutes interface{} `yaml:"timeout-minutes,omitempty"`
+	Extra          Extra       `yaml:",inline"`
+
+	Position Position `yaml:"-"`
 }
 
 type CompositeRun struct {
 	Using string    `yaml:"using"`
 	Steps []RunStep `yaml:"steps"`
+	Extra Extra     `yaml:",inline"`
 }
 
 type DockerRun struct {
+	Using          string    `yaml:"using"`
 	PreEntrypoint  *string   `yaml:"pre-entrypoint,omitempty"`
+	PreIf          *string   `yaml:"pre-if,omitempty"`
 	Image          string    `yaml:"image"`
 	Env            *EnvMap   `yaml:"env,omitempty"`
 	Entrypoint     *string   `yaml:"entrypoint,omitempty"`
 	PostEntrypoint *string   `yaml:"post-entrypoint,omitempty"`
+	PostIf         *string   `yaml:"post-if,omitempty"`
 	Args           *[]string `yaml:"args,omitempty"`
+	Extra          Extra     `yaml:",inline"`
 }
 
 type Runs struct {